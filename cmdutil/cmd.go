@@ -5,12 +5,36 @@ import (
 	"context"
 	"errors"
 	"os/exec"
+	"strings"
 	"sync"
+	"sync/atomic"
 	"syscall"
 
 	"go.uber.org/zap"
 )
 
+var (
+	dryRun        atomic.Bool
+	defaultLogger atomic.Pointer[zap.Logger]
+)
+
+// SetDryRun enables or disables dry run for every command, in dry run mode
+// commands are logged instead of being executed.
+func SetDryRun(enabled bool) {
+	dryRun.Store(enabled)
+}
+
+// IsDryRun reports whether global dry run is enabled.
+func IsDryRun() bool {
+	return dryRun.Load()
+}
+
+// SetLogger set the logger used by commands without WithLog and by
+// ExecCommand, zap.L() is used when it is not set.
+func SetLogger(log *zap.Logger) {
+	defaultLogger.Store(log)
+}
+
 // CmdMeta cmd meta
 type CmdMeta struct {
 	JobID string
@@ -28,6 +52,7 @@ type Cmd struct {
 	Stderr       *bytes.Buffer
 	IsSuccess    bool
 	Canceled     bool
+	DryRun       bool
 	hasCancelFun bool
 }
 
@@ -48,6 +73,13 @@ func WithLog(log *zap.Logger) Option {
 	}
 }
 
+// WithDryRun with dry run, the command is logged but never executed.
+func WithDryRun(enabled bool) Option {
+	return func(c *Cmd) {
+		c.DryRun = enabled
+	}
+}
+
 // Runner runner
 func Runner(m *CmdMeta, options ...Option) *Cmd {
 	ctx, cancel := context.WithCancel(context.Background())
@@ -74,9 +106,11 @@ func getCommand(command *exec.Cmd, cancel context.CancelFunc, options ...Option)
 	command.Stderr = &bytes.Buffer{}
 
 	c := &Cmd{
+		log:    defaultLogger.Load(),
 		cmd:    command,
 		mu:     sync.Mutex{},
 		Cancel: cancel,
+		DryRun: IsDryRun(),
 	}
 
 	if cancel == nil {
@@ -95,6 +129,11 @@ func (c *Cmd) Start() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.DryRun {
+		logDryRun(c.logger(), c.cmd)
+		return nil
+	}
+
 	if c.log != nil {
 		c.log.Info(c.cmd.String())
 	}
@@ -113,6 +152,13 @@ func (c *Cmd) Wait() error {
 	c.mu.Lock()
 	defer c.mu.Unlock()
 
+	if c.DryRun {
+		c.Stdout = &bytes.Buffer{}
+		c.Stderr = nil
+		c.IsSuccess = true
+		return nil
+	}
+
 	err := c.cmd.Wait()
 	if err != nil {
 		c.Stderr, _ = c.cmd.Stderr.(*bytes.Buffer)
@@ -160,12 +206,50 @@ func (c *Cmd) Stop() error {
 	return nil
 }
 
-// ExecCommand execCommand
-func ExecCommand(cmdStr string) (string, error) {
-	c := exec.Command("sh", "-c", cmdStr)
-	output, err := c.CombinedOutput()
+// ExecCommand execCommand, options such as WithDryRun and WithLog apply to
+// this call only.
+func ExecCommand(cmdStr string, options ...Option) (string, error) {
+	// the child keeps the process group of the caller, unlike getCommand.
+	c := &Cmd{
+		log:    defaultLogger.Load(),
+		cmd:    exec.Command("sh", "-c", cmdStr),
+		DryRun: IsDryRun(),
+	}
+	for _, option := range options {
+		option(c)
+	}
+	if c.DryRun {
+		logDryRun(c.logger(), c.cmd)
+		return "", nil
+	}
+	output, err := c.cmd.CombinedOutput()
 	if err != nil {
 		return "", err
 	}
 	return string(output), err
 }
+
+// logger returns the logger of the command, or the global zap logger so dry
+// runs are visible without SetLogger.
+func (c *Cmd) logger() *zap.Logger {
+	if c.log != nil {
+		return c.log
+	}
+	return zap.L()
+}
+
+// logDryRun log the command and dir that would run, and the names of the
+// environment variables set on it. The inherited environment and the values
+// are not logged, they may hold credentials.
+func logDryRun(log *zap.Logger, command *exec.Cmd) {
+	env := make([]string, 0, len(command.Env))
+	for _, kv := range command.Env {
+		name, _, _ := strings.Cut(kv, "=")
+		env = append(env, name)
+	}
+	log.Info("dry run",
+		zap.String("cmd", command.String()),
+		zap.String("dir", command.Dir),
+		zap.Strings("env", env),
+	)
+}
@@ -0,0 +1,85 @@
+package cmdutil
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestDryRun(t *testing.T) {
+	dir := t.TempDir()
+	marker := filepath.Join(dir, "marker")
+	core, logs := observer.New(zap.InfoLevel)
+
+	t.Setenv("DEPLOY_TOKEN", "tok-123")
+	c := RunnerWithCommand("touch", []string{marker}, WithDryRun(true), WithLog(zap.New(core)), WithDir(dir))
+	c.cmd.Env = []string{"MODE=fast", "API_KEY=key-123"}
+	err := c.Start()
+	if err != nil {
+		t.Fatal(err)
+	}
+	err = c.Wait()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !c.IsSuccess || c.Stdout.Len() != 0 {
+		t.Fatalf("unexpected result success=%v stdout=%q", c.IsSuccess, c.Stdout)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatal("dry run executed the command")
+	}
+	entries := logs.FilterMessage("dry run").All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d dry run entries", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if !strings.Contains(fields["cmd"].(string), "touch "+marker) || fields["dir"] != dir {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	// only the names of the variables set on the command.
+	if env := fmt.Sprint(fields["env"]); env != "[MODE API_KEY]" {
+		t.Fatalf("unexpected env %s", env)
+	}
+
+	// ExecCommand per call, falling back to the global logger.
+	globalCore, globalLogs := observer.New(zap.InfoLevel)
+	defer zap.ReplaceGlobals(zap.New(globalCore))()
+	out, err := ExecCommand("touch "+marker, WithDryRun(true))
+	if err != nil || out != "" {
+		t.Fatalf("unexpected output %q %v", out, err)
+	}
+	if _, err := os.Stat(marker); !os.IsNotExist(err) {
+		t.Fatal("dry run executed the command")
+	}
+	if globalLogs.FilterMessage("dry run").Len() != 1 {
+		t.Fatal("dry run not logged to the global logger")
+	}
+
+	// global dry run can be disabled per call.
+	SetDryRun(true)
+	defer SetDryRun(false)
+	if c := Runner(&CmdMeta{Name: "touch", Args: []string{marker}}); !c.DryRun {
+		t.Fatal("global dry run not applied")
+	}
+	out, err = ExecCommand("echo hello", WithDryRun(false))
+	if err != nil || out != "hello\n" {
+		t.Fatalf("unexpected output %q %v", out, err)
+	}
+}
+
+func TestExecCommandProcessGroup(t *testing.T) {
+	out, err := ExecCommand("ps -o pgid= -p $$")
+	if err != nil {
+		t.Skipf("ps not available: %v", err)
+	}
+	if pgid := strings.TrimSpace(out); pgid != strconv.Itoa(syscall.Getpgrp()) {
+		t.Fatalf("command runs in process group %s, want %d", pgid, syscall.Getpgrp())
+	}
+}
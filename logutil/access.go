@@ -0,0 +1,190 @@
+package logutil
+
+import (
+	"bufio"
+	"errors"
+	"net"
+	"net/http"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// AccessLogConfig access log config.
+type AccessLogConfig struct {
+	SkipPaths     []string `json:"skip_paths" yaml:"skip_paths"`         // 不记录的路径, 如 /healthz, 以 * 结尾时按前缀匹配, grpc 为完整方法名
+	SampleSuccess int      `json:"sample_success" yaml:"sample_success"` // 成功的请求每 SampleSuccess 条记录一条, 0 和 1 时全部记录
+}
+
+// AccessEntry an access log entry, it is logged with the keys method, path,
+// status, bytes, latency, remote_addr and request_id.
+type AccessEntry struct {
+	Level      zapcore.Level // 日志级别, info 为成功的请求
+	Method     string
+	Path       string
+	Status     int
+	Bytes      int64
+	Latency    time.Duration
+	RemoteAddr string
+	RequestID  string
+	Fields     []zap.Field // 其他字段
+}
+
+// AccessLogger log access entries with the skip and sampling rules of its
+// config, it is shared by the http middleware and the grpc interceptors.
+type AccessLogger struct {
+	logger     *zap.Logger
+	sample     uint64
+	skipExact  map[string]struct{}
+	skipPrefix []string
+	successes  atomic.Uint64
+}
+
+// NewAccessLogger new access logger writing to l.
+func NewAccessLogger(l *zap.Logger, cfg AccessLogConfig) *AccessLogger {
+	a := &AccessLogger{logger: l, skipExact: make(map[string]struct{})}
+	if cfg.SampleSuccess > 1 {
+		a.sample = uint64(cfg.SampleSuccess)
+	}
+	for _, path := range cfg.SkipPaths {
+		if prefix, ok := strings.CutSuffix(path, "*"); ok {
+			a.skipPrefix = append(a.skipPrefix, prefix)
+		} else {
+			a.skipExact[path] = struct{}{}
+		}
+	}
+	return a
+}
+
+// Skip reports whether requests of path are not logged.
+func (a *AccessLogger) Skip(path string) bool {
+	if _, ok := a.skipExact[path]; ok {
+		return true
+	}
+	for _, prefix := range a.skipPrefix {
+		if strings.HasPrefix(path, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// Log log the entry, info entries are sampled by SampleSuccess.
+func (a *AccessLogger) Log(ent AccessEntry) {
+	if ent.Level == zapcore.InfoLevel && a.sample > 0 && (a.successes.Add(1)-1)%a.sample != 0 {
+		return
+	}
+	ce := a.logger.Check(ent.Level, "access")
+	if ce == nil {
+		return
+	}
+	fields := make([]zap.Field, 0, 7+len(ent.Fields))
+	fields = append(fields,
+		zap.String("method", ent.Method),
+		zap.String("path", ent.Path),
+		zap.Int("status", ent.Status),
+		zap.Int64("bytes", ent.Bytes),
+		zap.Duration("latency", ent.Latency),
+		zap.String("remote_addr", ent.RemoteAddr),
+		zap.String("request_id", ent.RequestID),
+	)
+	ce.Write(append(fields, ent.Fields...)...)
+}
+
+// httpStatusLevel returns info for success, warn for 4xx and error for 5xx.
+func httpStatusLevel(status int) zapcore.Level {
+	switch {
+	case status >= http.StatusInternalServerError:
+		return zapcore.ErrorLevel
+	case status >= http.StatusBadRequest:
+		return zapcore.WarnLevel
+	}
+	return zapcore.InfoLevel
+}
+
+// AccessLogMiddleware log every request after it is served. The request id
+// is taken from ContextMiddleware, so put this middleware inside it, or from
+// the X-Request-ID header.
+func AccessLogMiddleware(l *zap.Logger, cfg AccessLogConfig) func(http.Handler) http.Handler {
+	a := NewAccessLogger(l, cfg)
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			if a.Skip(r.URL.Path) {
+				next.ServeHTTP(w, r)
+				return
+			}
+
+			start := time.Now()
+			rec := &responseRecorder{ResponseWriter: w}
+			next.ServeHTTP(rec, r)
+
+			status := rec.status
+			if status == 0 {
+				status = http.StatusOK
+			}
+			requestID := RequestIDFromContext(r.Context())
+			if id := r.Header.Get(RequestIDHeader); requestID == "" && ValidRequestID(id) {
+				requestID = id
+			}
+			a.Log(AccessEntry{
+				Level:      httpStatusLevel(status),
+				Method:     r.Method,
+				Path:       r.URL.Path,
+				Status:     status,
+				Bytes:      rec.bytes,
+				Latency:    time.Since(start),
+				RemoteAddr: r.RemoteAddr,
+				RequestID:  requestID,
+			})
+		})
+	}
+}
+
+// responseRecorder record the status and the number of bytes written.
+type responseRecorder struct {
+	http.ResponseWriter
+	status int
+	bytes  int64
+}
+
+func (w *responseRecorder) WriteHeader(status int) {
+	// informational responses are followed by the final status.
+	if w.status == 0 && status >= http.StatusOK {
+		w.status = status
+	}
+	w.ResponseWriter.WriteHeader(status)
+}
+
+func (w *responseRecorder) Write(p []byte) (int, error) {
+	if w.status == 0 {
+		w.status = http.StatusOK
+	}
+	n, err := w.ResponseWriter.Write(p)
+	w.bytes += int64(n)
+	return n, err
+}
+
+func (w *responseRecorder) Flush() {
+	if f, ok := w.ResponseWriter.(http.Flusher); ok {
+		f.Flush()
+	}
+}
+
+func (w *responseRecorder) Hijack() (net.Conn, *bufio.ReadWriter, error) {
+	h, ok := w.ResponseWriter.(http.Hijacker)
+	if !ok {
+		return nil, nil, errors.New("response writer does not support hijack")
+	}
+	if w.status == 0 {
+		w.status = http.StatusSwitchingProtocols
+	}
+	return h.Hijack()
+}
+
+// Unwrap returns the original writer for http.ResponseController.
+func (w *responseRecorder) Unwrap() http.ResponseWriter {
+	return w.ResponseWriter
+}
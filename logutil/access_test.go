@@ -0,0 +1,80 @@
+package logutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestAccessLogMiddleware(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	l := zap.New(core)
+	mux := http.NewServeMux()
+	mux.HandleFunc("/users", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write([]byte("hello"))
+	})
+	mux.HandleFunc("/fail", func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "boom", http.StatusBadGateway)
+	})
+	handler := ContextMiddleware(l)(AccessLogMiddleware(l, AccessLogConfig{
+		SkipPaths:     []string{"/healthz", "/debug/*"},
+		SampleSuccess: 2,
+	})(mux))
+
+	serve := func(path string) {
+		req := httptest.NewRequest(http.MethodGet, path, nil)
+		req.Header.Set(RequestIDHeader, "req-1")
+		req.RemoteAddr = "10.0.0.1:1234"
+		handler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+	for i := 0; i < 3; i++ {
+		serve("/users")
+	}
+	serve("/healthz")
+	serve("/debug/pprof")
+	serve("/missing")
+	serve("/fail")
+
+	entries := logs.All()
+	if len(entries) != 4 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	want := map[string]interface{}{
+		"method":      http.MethodGet,
+		"path":        "/users",
+		"status":      int64(http.StatusOK),
+		"bytes":       int64(5),
+		"remote_addr": "10.0.0.1:1234",
+		"request_id":  "req-1",
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("field %s = %v, want %v", key, fields[key], value)
+		}
+	}
+	if _, ok := fields["latency"]; !ok || entries[0].Message != "access" {
+		t.Errorf("unexpected entry %+v", entries[0])
+	}
+
+	// the second success is sampled out.
+	for i, want := range []struct {
+		level  zapcore.Level
+		path   string
+		status int64
+	}{
+		{zapcore.InfoLevel, "/users", 200},
+		{zapcore.WarnLevel, "/missing", 404},
+		{zapcore.ErrorLevel, "/fail", 502},
+	} {
+		ent := entries[i+1]
+		fields := ent.ContextMap()
+		if ent.Level != want.level || fields["path"] != want.path || fields["status"] != want.status {
+			t.Errorf("entry %d: %s %v %v", i+1, ent.Level, fields["path"], fields["status"])
+		}
+	}
+}
@@ -0,0 +1,198 @@
+package logutil
+
+import (
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// overflow policies of AsyncConfig.
+const (
+	OverflowBlock      = "block"
+	OverflowDropOldest = "drop_oldest"
+	OverflowDropNewest = "drop_newest"
+)
+
+const (
+	defaultAsyncBufferSize    = 1024
+	defaultAsyncFlushInterval = time.Second
+)
+
+// AsyncConfig async write config.
+type AsyncConfig struct {
+	BufferSize    int           `json:"buffer_size" yaml:"buffer_size"`       // 缓冲的最多日志条数, 默认 1024
+	FlushInterval time.Duration `json:"flush_interval" yaml:"flush_interval"` // 刷新间隔, 默认 1s
+	Overflow      string        `json:"overflow" yaml:"overflow"`             // 缓冲满时的策略 block, drop_oldest, drop_newest, 默认 block
+}
+
+// AsyncWriteSyncer buffer writes in memory and write them to the underlying
+// WriteSyncer in background every FlushInterval or when the buffer is full.
+// Sync flushes the buffer and syncs the underlying WriteSyncer, Close flushes
+// and stops the background goroutine.
+type AsyncWriteSyncer struct {
+	ws       zapcore.WriteSyncer
+	size     int
+	interval time.Duration
+	overflow string
+
+	mu      sync.Mutex
+	notFull *sync.Cond
+	queue   [][]byte
+	stopped bool
+	writeMu sync.Mutex
+	dropped atomic.Uint64
+
+	flushCh  chan struct{}
+	stopCh   chan struct{}
+	done     chan struct{}
+	stopOnce sync.Once
+}
+
+// NewAsyncWriteSyncer new async write syncer.
+func NewAsyncWriteSyncer(ws zapcore.WriteSyncer, cfg AsyncConfig) (*AsyncWriteSyncer, error) {
+	if cfg.BufferSize <= 0 {
+		cfg.BufferSize = defaultAsyncBufferSize
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultAsyncFlushInterval
+	}
+	switch cfg.Overflow {
+	case "":
+		cfg.Overflow = OverflowBlock
+	case OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+	default:
+		return nil, fmt.Errorf("unknown overflow policy %q", cfg.Overflow)
+	}
+
+	w := &AsyncWriteSyncer{
+		ws:       ws,
+		size:     cfg.BufferSize,
+		interval: cfg.FlushInterval,
+		overflow: cfg.Overflow,
+		queue:    make([][]byte, 0, cfg.BufferSize),
+		flushCh:  make(chan struct{}, 1),
+		stopCh:   make(chan struct{}),
+		done:     make(chan struct{}),
+	}
+	w.notFull = sync.NewCond(&w.mu)
+	go w.run()
+	return w, nil
+}
+
+// Write buffer p, when the buffer is full it blocks or drops an entry by the
+// overflow policy. After Close it writes to the underlying WriteSyncer directly.
+func (w *AsyncWriteSyncer) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	for !w.stopped && len(w.queue) >= w.size {
+		switch w.overflow {
+		case OverflowDropNewest:
+			w.mu.Unlock()
+			w.dropped.Add(1)
+			return len(p), nil
+		case OverflowDropOldest:
+			w.queue[0] = nil
+			w.queue = w.queue[1:]
+			w.dropped.Add(1)
+		default:
+			w.requestFlush()
+			w.notFull.Wait()
+		}
+	}
+	if w.stopped {
+		w.mu.Unlock()
+		w.writeMu.Lock()
+		defer w.writeMu.Unlock()
+		return w.ws.Write(p)
+	}
+
+	w.queue = append(w.queue, append([]byte(nil), p...))
+	if len(w.queue) >= w.size {
+		w.requestFlush()
+	}
+	w.mu.Unlock()
+	return len(p), nil
+}
+
+// Sync flush the buffer and sync the underlying WriteSyncer.
+func (w *AsyncWriteSyncer) Sync() error {
+	err := w.flush()
+	if err != nil {
+		return err
+	}
+	return w.ws.Sync()
+}
+
+// Close flush the buffer and stop the background goroutine.
+func (w *AsyncWriteSyncer) Close() error {
+	w.stopOnce.Do(func() {
+		w.mu.Lock()
+		w.stopped = true
+		w.notFull.Broadcast()
+		w.mu.Unlock()
+
+		close(w.stopCh)
+		<-w.done
+	})
+	return w.Sync()
+}
+
+// Dropped returns the number of entries dropped by the overflow policy or
+// failed to write in background.
+func (w *AsyncWriteSyncer) Dropped() uint64 {
+	return w.dropped.Load()
+}
+
+func (w *AsyncWriteSyncer) requestFlush() {
+	select {
+	case w.flushCh <- struct{}{}:
+	default:
+	}
+}
+
+func (w *AsyncWriteSyncer) run() {
+	defer close(w.done)
+
+	ticker := time.NewTicker(w.interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-w.stopCh:
+			_ = w.flush()
+			return
+		case <-ticker.C:
+			_ = w.flush()
+		case <-w.flushCh:
+			_ = w.flush()
+		}
+	}
+}
+
+// flush write the buffered entries to the underlying WriteSyncer one by one,
+// so a sink limiting the write size such as lumberjack only rejects the
+// oversized entries. Entries failed to write are counted as dropped, the
+// first error is returned.
+func (w *AsyncWriteSyncer) flush() error {
+	w.writeMu.Lock()
+	defer w.writeMu.Unlock()
+
+	w.mu.Lock()
+	queue := w.queue
+	w.queue = make([][]byte, 0, w.size)
+	w.notFull.Broadcast()
+	w.mu.Unlock()
+
+	var firstErr error
+	for _, p := range queue {
+		_, err := w.ws.Write(p)
+		if err != nil {
+			w.dropped.Add(1)
+			if firstErr == nil {
+				firstErr = err
+			}
+		}
+	}
+	return firstErr
+}
@@ -0,0 +1,148 @@
+package logutil
+
+import (
+	"bytes"
+	"context"
+	"fmt"
+	"sync"
+	"testing"
+	"time"
+)
+
+// gateWriter blocks the first write until the gate is opened.
+type gateWriter struct {
+	mu      sync.Mutex
+	buf     bytes.Buffer
+	entered chan struct{}
+	gate    chan struct{}
+	once    sync.Once
+}
+
+func (w *gateWriter) Write(p []byte) (int, error) {
+	w.once.Do(func() {
+		close(w.entered)
+		<-w.gate
+	})
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	return w.buf.Write(p)
+}
+
+func (w *gateWriter) Sync() error {
+	return nil
+}
+
+func TestAsyncWriteSyncerOverflow(t *testing.T) {
+	tests := []struct {
+		overflow string
+		want     string
+	}{
+		{OverflowDropNewest, "abcd"},
+		{OverflowDropOldest, "abde"},
+	}
+	for _, tt := range tests {
+		t.Run(tt.overflow, func(t *testing.T) {
+			ws := &gateWriter{entered: make(chan struct{}), gate: make(chan struct{})}
+			w, err := NewAsyncWriteSyncer(ws, AsyncConfig{BufferSize: 2, FlushInterval: time.Hour, Overflow: tt.overflow})
+			if err != nil {
+				t.Fatal(err)
+			}
+
+			_, _ = w.Write([]byte("a"))
+			_, _ = w.Write([]byte("b"))
+			<-ws.entered
+			for _, p := range []string{"c", "d", "e"} {
+				_, _ = w.Write([]byte(p))
+			}
+			close(ws.gate)
+
+			err = w.Close()
+			if err != nil {
+				t.Fatal(err)
+			}
+			if got := ws.buf.String(); got != tt.want {
+				t.Errorf("got %q, want %q", got, tt.want)
+			}
+			if w.Dropped() != 1 {
+				t.Errorf("dropped %d, want 1", w.Dropped())
+			}
+		})
+	}
+}
+
+// limitWriter rejects writes larger than max like lumberjack.
+type limitWriter struct {
+	max int
+	buf bytes.Buffer
+}
+
+func (w *limitWriter) Write(p []byte) (int, error) {
+	if len(p) > w.max {
+		return 0, fmt.Errorf("write length %d exceeds maximum %d", len(p), w.max)
+	}
+	return w.buf.Write(p)
+}
+
+func (w *limitWriter) Sync() error {
+	return nil
+}
+
+func TestAsyncWriteSyncerWriteError(t *testing.T) {
+	ws := &limitWriter{max: 4}
+	w, err := NewAsyncWriteSyncer(ws, AsyncConfig{BufferSize: 10, FlushInterval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, p := range []string{"abc", "toolong", "def", "ghi"} {
+		_, _ = w.Write([]byte(p))
+	}
+
+	// the entries are written one by one, only the oversized one is lost.
+	err = w.Sync()
+	if err == nil {
+		t.Fatal("expected write error")
+	}
+	if got := ws.buf.String(); got != "abcdefghi" {
+		t.Errorf("got %q", got)
+	}
+	if w.Dropped() != 1 {
+		t.Errorf("dropped %d, want 1", w.Dropped())
+	}
+	err = w.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestRegistryAsyncDropped(t *testing.T) {
+	ws := &gateWriter{entered: make(chan struct{}), gate: make(chan struct{})}
+	r := NewRegistry()
+	l, err := r.Register("app", &Config{
+		LogLevel: "info",
+		Outputs:  []Output{{Type: OutputWriter, Writer: ws}},
+		Async:    &AsyncConfig{BufferSize: 1, FlushInterval: time.Hour, Overflow: OverflowDropNewest},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// the full buffer is flushed and blocks in the writer.
+	l.Info("first")
+	select {
+	case <-ws.entered:
+	case <-time.After(5 * time.Second):
+		t.Fatal("buffer not flushed")
+	}
+	for i := 0; i < 3; i++ {
+		l.Info("overflow")
+	}
+	dropped, ok := r.Dropped("app")
+	close(ws.gate)
+	if !ok || dropped[DroppedAsync] == 0 {
+		t.Fatalf("unexpected dropped %v %v", dropped, ok)
+	}
+	if _, ok := r.Dropped("missing"); ok {
+		t.Fatal("unexpected dropped of missing logger")
+	}
+	_ = r.Shutdown(context.Background())
+}
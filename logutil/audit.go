@@ -0,0 +1,405 @@
+package logutil
+
+import (
+	"bytes"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"strconv"
+	"sync"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// keys of the audit fields.
+const (
+	auditSeqKey      = "seq"
+	auditPrevHashKey = "prev_hash"
+	auditHashKey     = "hash"
+	auditHMACKey     = "hmac"
+	auditTruncKey    = "truncated_lines"
+)
+
+// AuditConfig audit output config.
+type AuditConfig struct {
+	HMACKey string `json:"hmac_key" yaml:"hmac_key"` // HMAC-SHA256 签名密钥, 为空时不签名
+}
+
+// AuditReport the result of VerifyAudit.
+type AuditReport struct {
+	Files   []string    // 校验的文件, 旧的在前
+	Entries int         // 校验通过的条数
+	LastSeq uint64      // 最后一条校验通过的序号
+	Skipped int         // 崩溃时写了一半被跳过的行数
+	Broken  *AuditBreak // 第一处断裂, 为空时日志完整
+}
+
+// AuditBreak the first broken or missing entry found by VerifyAudit.
+type AuditBreak struct {
+	File   string
+	Line   int
+	Seq    uint64 // 断裂处的序号, 无法解析时为期望的序号
+	Reason string
+}
+
+func (b *AuditBreak) Error() string {
+	return fmt.Sprintf("%s:%d: seq %d: %s", b.File, b.Line, b.Seq, b.Reason)
+}
+
+// auditChain the chain state shared by the audit cores created by With.
+type auditChain struct {
+	mu        sync.Mutex
+	seq       uint64
+	hash      string
+	truncated int // partial lines skipped at start, recorded by the next entry
+	key       []byte
+	out       zapcore.WriteSyncer
+}
+
+type auditCore struct {
+	zapcore.LevelEnabler
+	enc   zapcore.Encoder
+	chain *auditChain
+}
+
+// NewAuditCore new core writing hash chained entries with enc, which must be
+// a json encoder. Every entry has seq, prev_hash, the hash of the entry
+// itself and, with cfg.HMACKey, its hmac. The chain continues from the last
+// entry of filename and its backups, empty filename starts a new chain.
+// Partial lines left at the end by a crash are skipped, the next entry
+// continues from the last complete one and records the skipped lines in
+// truncated_lines, which VerifyAudit accepts.
+func NewAuditCore(enc zapcore.Encoder, out zapcore.WriteSyncer, enab zapcore.LevelEnabler, cfg AuditConfig, filename string) (zapcore.Core, error) {
+	chain := &auditChain{out: out}
+	if cfg.HMACKey != "" {
+		chain.key = []byte(cfg.HMACKey)
+	}
+	if filename != "" {
+		last, err := lastAuditEntry(filename)
+		if err != nil {
+			return nil, err
+		}
+		chain.seq, chain.hash, chain.truncated = last.seq, last.hash, last.skipped
+		if last.unterminated {
+			// end the partial line so the next entry starts a line.
+			if _, err := out.Write([]byte{'\n'}); err != nil {
+				return nil, err
+			}
+		}
+	}
+	return &auditCore{LevelEnabler: enab, enc: enc, chain: chain}, nil
+}
+
+// newAuditOutputCore new audit core of a file output, it is written
+// synchronously so no entry is dropped.
+func newAuditOutputCore(out Output, enab zapcore.LevelEnabler, s *sinks) (zapcore.Core, error) {
+	var cfg AuditConfig
+	if out.Audit != nil {
+		cfg = *out.Audit
+	}
+	encoder, err := getEncoder("json", out.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	fileOut := out
+	fileOut.Type = OutputFile
+	writeSyncer, err := getLogWriter(fileOut, s)
+	if err != nil {
+		return nil, err
+	}
+	return NewAuditCore(encoder, writeSyncer, enab, cfg, filepath.Join(out.Path, out.FileName))
+}
+
+func (c *auditCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &auditCore{LevelEnabler: c.LevelEnabler, enc: enc, chain: c.chain}
+}
+
+func (c *auditCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+// Write encode and write the entry under the chain lock, so the order of
+// the file is the order of the chain.
+func (c *auditCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	c.chain.mu.Lock()
+	defer c.chain.mu.Unlock()
+
+	seq := c.chain.seq + 1
+	all := make([]zapcore.Field, 0, len(fields)+3)
+	all = append(all, zap.Uint64(auditSeqKey, seq), zap.String(auditPrevHashKey, c.chain.hash))
+	if c.chain.truncated > 0 {
+		all = append(all, zap.Int(auditTruncKey, c.chain.truncated))
+	}
+	all = append(all, fields...)
+	buf, err := c.enc.EncodeEntry(ent, all)
+	if err != nil {
+		return err
+	}
+	line, hash, err := sealAuditEntry(buf.Bytes(), c.chain.key)
+	buf.Free()
+	if err != nil {
+		return err
+	}
+	_, err = c.chain.out.Write(line)
+	if err != nil {
+		return err
+	}
+	c.chain.seq, c.chain.hash, c.chain.truncated = seq, hash, 0
+
+	if ent.Level > zapcore.ErrorLevel {
+		return c.chain.out.Sync()
+	}
+	return nil
+}
+
+func (c *auditCore) Sync() error {
+	c.chain.mu.Lock()
+	defer c.chain.mu.Unlock()
+	return c.chain.out.Sync()
+}
+
+// sealAuditEntry append the hash of the json body, and its hmac when key is
+// not empty, as the last fields. It returns the line and the hash.
+func sealAuditEntry(body []byte, key []byte) ([]byte, string, error) {
+	body = bytes.TrimRight(body, "\n")
+	if len(body) < 2 || body[len(body)-1] != '}' {
+		return nil, "", fmt.Errorf("audit entry is not a json object: %q", body)
+	}
+	hash := auditHash(body)
+
+	line := make([]byte, 0, len(body)+160)
+	line = append(line, body[:len(body)-1]...)
+	line = append(line, auditSuffix(hash, auditHMAC(key, body))...)
+	line = append(line, '\n')
+	return line, hash, nil
+}
+
+func auditHash(body []byte) string {
+	sum := sha256.Sum256(body)
+	return hex.EncodeToString(sum[:])
+}
+
+// auditHMAC returns the hmac of body, or empty when key is empty.
+func auditHMAC(key, body []byte) string {
+	if len(key) == 0 {
+		return ""
+	}
+	mac := hmac.New(sha256.New, key)
+	mac.Write(body)
+	return hex.EncodeToString(mac.Sum(nil))
+}
+
+// auditSuffix returns the hash and hmac fields closing the entry.
+func auditSuffix(hash, mac string) string {
+	suffix := `,"` + auditHashKey + `":"` + hash + `"`
+	if mac != "" {
+		suffix += `,"` + auditHMACKey + `":"` + mac + `"`
+	}
+	return suffix + "}"
+}
+
+type auditEntry struct {
+	seq       uint64
+	prev      string
+	hash      string
+	mac       string
+	truncated int    // partial lines skipped before the entry
+	body      []byte // the json without hash and hmac
+}
+
+// parseAuditEntry parse the line and check its hash.
+func parseAuditEntry(line []byte) (auditEntry, error) {
+	line = bytes.TrimRight(line, "\r\n")
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if err := decoder.Decode(&fields); err != nil {
+		return auditEntry{}, fmt.Errorf("not a json entry: %w", err)
+	}
+
+	var ent auditEntry
+	seq, _ := fields[auditSeqKey].(json.Number)
+	n, err := strconv.ParseUint(seq.String(), 10, 64)
+	if err != nil {
+		return auditEntry{}, fmt.Errorf("invalid %s %q", auditSeqKey, seq)
+	}
+	ent.seq = n
+	ent.prev, _ = fields[auditPrevHashKey].(string)
+	ent.hash, _ = fields[auditHashKey].(string)
+	ent.mac, _ = fields[auditHMACKey].(string)
+	if truncated, ok := fields[auditTruncKey].(json.Number); ok {
+		n, err := strconv.Atoi(truncated.String())
+		if err != nil || n < 0 {
+			return auditEntry{}, fmt.Errorf("invalid %s %q", auditTruncKey, truncated)
+		}
+		ent.truncated = n
+	}
+	if ent.hash == "" {
+		return ent, fmt.Errorf("missing %s", auditHashKey)
+	}
+
+	suffix := auditSuffix(ent.hash, ent.mac)
+	if !bytes.HasSuffix(line, []byte(suffix)) {
+		return ent, fmt.Errorf("%s and %s are not the last fields", auditHashKey, auditHMACKey)
+	}
+	ent.body = append(line[:len(line)-len(suffix):len(line)-len(suffix)], '}')
+	if auditHash(ent.body) != ent.hash {
+		return ent, fmt.Errorf("%s mismatch, the entry is modified", auditHashKey)
+	}
+	return ent, nil
+}
+
+// partialAuditLine reports whether the line is not a complete json value,
+// as left by a crash in the middle of a write. Complete lines which are not
+// valid entries are not partial, they are modified.
+func partialAuditLine(line []byte) bool {
+	return !json.Valid(bytes.TrimSpace(line))
+}
+
+// auditTail the end of the chain found by lastAuditEntry.
+type auditTail struct {
+	seq          uint64
+	hash         string
+	skipped      int  // partial lines after the last entry
+	unterminated bool // filename ends without a newline
+}
+
+// lastAuditEntry returns the last entry of filename and its backups, zero
+// values when there are no entries. Partial lines after it are skipped and
+// counted, a complete line which is not a valid entry is an error.
+func lastAuditEntry(filename string) (auditTail, error) {
+	var tail auditTail
+	files, err := LogFiles(filename)
+	if err != nil {
+		return tail, err
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		var (
+			last    []byte
+			skipped int
+			end     []byte
+		)
+		_, err := readLines(files[i], func(line []byte) bool {
+			end = append(end[:0], line...)
+			switch {
+			case len(bytes.TrimSpace(line)) == 0:
+			case partialAuditLine(line):
+				skipped++
+			default:
+				last = append(last[:0], line...)
+				skipped = 0
+			}
+			return true
+		})
+		if err != nil {
+			return tail, err
+		}
+		if files[i] == filename && len(end) > 0 && end[len(end)-1] != '\n' {
+			tail.unterminated = true
+		}
+		tail.skipped += skipped
+		if last == nil {
+			continue
+		}
+		ent, err := parseAuditEntry(last)
+		if err != nil {
+			return tail, fmt.Errorf("audit log %s: last entry: %w", files[i], err)
+		}
+		tail.seq, tail.hash = ent.seq, ent.hash
+		return tail, nil
+	}
+	return tail, nil
+}
+
+// VerifyAudit verify the audit log filename and its backups oldest first, it
+// stops at the first entry which is modified, out of order, not chained to
+// the previous one or has a wrong hmac, and at the first gap of seq. Removed
+// backups are reported as missing entries. Partial lines left by a crash are
+// skipped when the next entry records them in truncated_lines, otherwise the
+// first of them is the break. The hmac is checked when hmacKey is not empty.
+func VerifyAudit(filename string, hmacKey string) (*AuditReport, error) {
+	files, err := LogFiles(filename)
+	if err != nil {
+		return nil, err
+	}
+	report := &AuditReport{Files: files}
+	var key []byte
+	if hmacKey != "" {
+		key = []byte(hmacKey)
+	}
+
+	var (
+		prevHash string
+		partial  []*AuditBreak // partial lines since the last entry
+	)
+	for _, file := range files {
+		lineNo := 0
+		_, err := readLines(file, func(line []byte) bool {
+			lineNo++
+			if len(bytes.TrimSpace(line)) == 0 {
+				return true
+			}
+			expect := report.LastSeq + 1
+			broken := func(seq uint64, format string, args ...interface{}) bool {
+				report.Broken = &AuditBreak{File: file, Line: lineNo, Seq: seq, Reason: fmt.Sprintf(format, args...)}
+				return false
+			}
+
+			ent, err := parseAuditEntry(line)
+			if err != nil {
+				if partialAuditLine(line) {
+					partial = append(partial, &AuditBreak{File: file, Line: lineNo, Seq: expect, Reason: err.Error()})
+					return true
+				}
+				return broken(expect, "%v", err)
+			}
+			if ent.truncated != len(partial) {
+				if len(partial) > 0 {
+					report.Broken = partial[0]
+					return false
+				}
+				return broken(ent.seq, "%s %d without partial lines", auditTruncKey, ent.truncated)
+			}
+			switch {
+			case ent.seq > expect:
+				return broken(ent.seq, "entries %d to %d are missing", expect, ent.seq-1)
+			case ent.seq < expect:
+				return broken(ent.seq, "seq %d is out of order, expect %d", ent.seq, expect)
+			case ent.prev != prevHash:
+				return broken(ent.seq, "%s does not match the previous entry", auditPrevHashKey)
+			case key != nil && ent.mac == "":
+				return broken(ent.seq, "missing %s", auditHMACKey)
+			case key != nil && !hmac.Equal([]byte(ent.mac), []byte(auditHMAC(key, ent.body))):
+				return broken(ent.seq, "%s mismatch", auditHMACKey)
+			}
+			report.Entries++
+			report.LastSeq = ent.seq
+			report.Skipped += len(partial)
+			prevHash = ent.hash
+			partial = nil
+			return true
+		})
+		if err != nil {
+			return nil, err
+		}
+		if report.Broken != nil {
+			return report, nil
+		}
+	}
+	if len(partial) > 0 {
+		// not followed by an entry yet, the logger has not restarted.
+		report.Broken = partial[0]
+	}
+	return report, nil
+}
@@ -0,0 +1,256 @@
+package logutil
+
+import (
+	"bytes"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestAuditOutput(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "audit.log")
+	newAudit := func() *zap.Logger {
+		cfg := Config{
+			LogLevel:    "debug",
+			LogPath:     dir,
+			LogFileName: "audit.log",
+			Outputs:     []Output{{Type: OutputAudit, Audit: &AuditConfig{HMACKey: "secret"}}},
+		}
+		l, _, s, err := newLogger(&cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = s.close() })
+		return l
+	}
+
+	// the chain continues across restarts and backups.
+	l := newAudit()
+	l.Info("login", zap.String("user", "alice"))
+	l.With(zap.String("user", "bob")).Warn("delete", zap.Int("id", 7))
+	_ = l.Sync()
+	err := os.Rename(filename, filepath.Join(dir, "audit-2024-01-02T15-04-05.000.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = newAudit()
+	l.Info("logout", zap.String("user", "alice"))
+	_ = l.Sync()
+
+	report, err := VerifyAudit(filename, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Broken != nil || report.Entries != 3 || report.LastSeq != 3 || len(report.Files) != 2 {
+		t.Fatalf("unexpected report %+v %v", report, report.Broken)
+	}
+
+	report, err = VerifyAudit(filename, "wrong")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Broken == nil || report.Broken.Seq != 1 || report.Broken.Reason != "hmac mismatch" {
+		t.Fatalf("unexpected break %+v", report.Broken)
+	}
+
+	tests := []struct {
+		name   string
+		edit   func(lines [][]byte) [][]byte
+		seq    uint64
+		line   int
+		reason string
+	}{
+		{
+			name: "modified",
+			edit: func(lines [][]byte) [][]byte {
+				lines[1] = bytes.Replace(lines[1], []byte(`"id":7`), []byte(`"id":8`), 1)
+				return lines
+			},
+			seq: 2, line: 2, reason: "hash mismatch",
+		},
+		{
+			name: "removed",
+			edit: func(lines [][]byte) [][]byte {
+				return lines[1:]
+			},
+			seq: 2, line: 1, reason: "entries 1 to 1 are missing",
+		},
+		{
+			name: "reordered",
+			edit: func(lines [][]byte) [][]byte {
+				lines[0], lines[1] = lines[1], lines[0]
+				return lines
+			},
+			seq: 2, line: 1, reason: "entries 1 to 1 are missing",
+		},
+		{
+			name: "not audit",
+			edit: func(lines [][]byte) [][]byte {
+				return append(lines, []byte(`{"msg":"forged"}`))
+			},
+			seq: 3, line: 3, reason: "invalid seq",
+		},
+	}
+	backup := filepath.Join(dir, "audit-2024-01-02T15-04-05.000.log")
+	data, err := os.ReadFile(backup)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			lines := bytes.Split(bytes.TrimSpace(data), []byte("\n"))
+			lines = tt.edit(lines)
+			err := os.WriteFile(backup, append(bytes.Join(lines, []byte("\n")), '\n'), 0o644)
+			if err != nil {
+				t.Fatal(err)
+			}
+			defer func() { _ = os.WriteFile(backup, data, 0o644) }()
+
+			report, err := VerifyAudit(filename, "secret")
+			if err != nil {
+				t.Fatal(err)
+			}
+			b := report.Broken
+			if b == nil || b.File != backup || b.Line != tt.line || b.Seq != tt.seq || !strings.HasPrefix(b.Reason, tt.reason) {
+				t.Fatalf("unexpected break %+v", b)
+			}
+		})
+	}
+
+	// an unchained entry in the current file breaks the next one.
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	line, _, err := sealAuditEntry([]byte(`{"msg":"forged","seq":4,"prev_hash":"x"}`), []byte("secret"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.Write(line)
+	_ = f.Close()
+	report, err = VerifyAudit(filename, "secret")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := report.Broken; b == nil || b.File != filename || b.Seq != 4 || b.Reason != "prev_hash does not match the previous entry" {
+		t.Fatalf("unexpected break %+v", b)
+	}
+}
+
+func TestAuditOutputSampling(t *testing.T) {
+	dir := t.TempDir()
+	var buf bytes.Buffer
+	cfg := Config{
+		LogLevel:        "debug",
+		LogPath:         dir,
+		LogFileName:     "audit.log",
+		LogModuleLevels: "db=error",
+		Outputs: []Output{
+			{Type: OutputWriter, Writer: &buf, Format: "json"},
+			{Type: OutputAudit},
+		},
+		Sampling:  []SamplingConfig{{Level: "info", Initial: 1, Thereafter: 0, Tick: time.Hour}},
+		RateLimit: []RateLimitConfig{{Level: "warn", Limit: 1, Interval: time.Hour}},
+	}
+	l, _, s, err := newLogger(&cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Cleanup(func() { _ = s.close() })
+
+	// sampled, rate limited and muted entries are all audited.
+	for i := 0; i < 3; i++ {
+		l.Info("login", zap.Int("i", i))
+		l.Warn("denied", zap.Int("i", i))
+	}
+	l.Named("db").Info("query")
+	_ = l.Sync()
+	// one login, one denied and the summary of the suppressed ones.
+	if n := strings.Count(buf.String(), "\n"); n != 3 {
+		t.Fatalf("expect 3 entries, got %d: %s", n, buf.String())
+	}
+
+	report, err := VerifyAudit(filepath.Join(dir, "audit.log"), "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Broken != nil || report.Entries != 7 {
+		t.Fatalf("unexpected report %+v %v", report, report.Broken)
+	}
+}
+
+func TestAuditTruncated(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "audit.log")
+	newAudit := func() *zap.Logger {
+		cfg := Config{
+			LogLevel:    "info",
+			LogPath:     dir,
+			LogFileName: "audit.log",
+			Outputs:     []Output{{Type: OutputAudit}},
+		}
+		l, _, s, err := newLogger(&cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		t.Cleanup(func() { _ = s.close() })
+		return l
+	}
+
+	l := newAudit()
+	l.Info("login")
+	l.Info("logout")
+	_ = l.Sync()
+
+	// a crash in the middle of the third entry.
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"level":"INFO","msg":"login","seq":3,"prev_h`)
+	_ = f.Close()
+	report, err := VerifyAudit(filename, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if b := report.Broken; b == nil || b.Line != 3 || b.Seq != 3 || report.Entries != 2 {
+		t.Fatalf("unexpected report %+v %v", report, b)
+	}
+
+	// the restart continues from the second entry and records the skip.
+	l = newAudit()
+	l.Info("login")
+	_ = l.Sync()
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 4 || !strings.Contains(lines[3], `"seq":3,`) || !strings.Contains(lines[3], `"truncated_lines":1`) {
+		t.Fatalf("unexpected lines %q", lines)
+	}
+	report, err = VerifyAudit(filename, "")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if report.Broken != nil || report.Entries != 3 || report.LastSeq != 3 || report.Skipped != 1 {
+		t.Fatalf("unexpected report %+v %v", report, report.Broken)
+	}
+
+	// a complete line which is not an entry is not skipped.
+	f, err = os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"msg":"forged"}` + "\n")
+	_ = f.Close()
+	cfg := Config{LogPath: dir, LogFileName: "audit.log", Outputs: []Output{{Type: OutputAudit}}}
+	if _, _, _, err := newLogger(&cfg); err == nil {
+		t.Fatal("expect error for a modified last entry")
+	}
+}
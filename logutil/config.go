@@ -0,0 +1,416 @@
+package logutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+	"gopkg.in/yaml.v3"
+)
+
+// log types by name, used by the LOG_TYPE environment variable.
+var logTypes = map[string]int{
+	"stdout":          LogStdout,
+	"file":            LogFile,
+	"stdout_and_file": LogStdoutAndFile,
+}
+
+// LoadConfig load config from the yaml or json file and then the LOG_*
+// environment variables, the file is skipped when path is empty. Fields not
+// set use the default config.
+func LoadConfig(path string) (*Config, error) {
+	cfg := *defaultConfig
+	if path != "" {
+		err := decodeConfigFile(path, &cfg)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	err := ApplyEnv(&cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// LoadConfigFile load config from the yaml or json file.
+func LoadConfigFile(path string) (*Config, error) {
+	cfg := *defaultConfig
+	err := decodeConfigFile(path, &cfg)
+	if err != nil {
+		return nil, err
+	}
+	err = cfg.Validate()
+	if err != nil {
+		return nil, err
+	}
+	return &cfg, nil
+}
+
+// decodeConfigFile decode yaml or json, json is parsed as yaml. Durations are
+// strings like "1s", unknown fields are errors.
+func decodeConfigFile(path string, cfg *Config) error {
+	data, err := os.ReadFile(path)
+	if err != nil {
+		return err
+	}
+	decoder := yaml.NewDecoder(bytes.NewReader(data))
+	decoder.KnownFields(true)
+	err = decoder.Decode(cfg)
+	if err != nil && !errors.Is(err, io.EOF) {
+		return fmt.Errorf("log config %s: %w", path, err)
+	}
+	return nil
+}
+
+// ApplyEnv override cfg with the environment variables LOG_LEVEL, LOG_FORMAT,
+// LOG_PATH, LOG_FILE_NAME, LOG_FILE_MAX_SIZE, LOG_FILE_MAX_BACKUPS,
+// LOG_MAX_AGE, LOG_COMPRESS, LOG_ROTATE, LOG_TYPE, LOG_CALLER and
+// LOG_MODULE_LEVELS.
+func ApplyEnv(cfg *Config) error {
+	var errs []error
+	setString := func(name string, field *string) {
+		if value, ok := os.LookupEnv(name); ok {
+			*field = value
+		}
+	}
+	setInt := func(name string, field *int) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		n, err := strconv.Atoi(strings.TrimSpace(value))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid integer %q", name, value))
+			return
+		}
+		*field = n
+	}
+	setBool := func(name string, field *bool) {
+		value, ok := os.LookupEnv(name)
+		if !ok {
+			return
+		}
+		b, err := strconv.ParseBool(strings.TrimSpace(value))
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: invalid bool %q", name, value))
+			return
+		}
+		*field = b
+	}
+
+	setString("LOG_LEVEL", &cfg.LogLevel)
+	setString("LOG_FORMAT", &cfg.LogFormat)
+	setString("LOG_PATH", &cfg.LogPath)
+	setString("LOG_FILE_NAME", &cfg.LogFileName)
+	setInt("LOG_FILE_MAX_SIZE", &cfg.LogFileMaxSize)
+	setInt("LOG_FILE_MAX_BACKUPS", &cfg.LogFileMaxBackups)
+	setInt("LOG_MAX_AGE", &cfg.LogMaxAge)
+	setBool("LOG_COMPRESS", &cfg.LogCompress)
+	setString("LOG_ROTATE", &cfg.LogRotate)
+	setBool("LOG_CALLER", &cfg.Caller)
+	setString("LOG_MODULE_LEVELS", &cfg.LogModuleLevels)
+	setInt("LOG_CALLER_SKIP", &cfg.CallerSkip)
+	setString("LOG_STACKTRACE_LEVEL", &cfg.StacktraceLevel)
+	for _, name := range []string{"LOG_TIME_LAYOUT", "LOG_TIME_ZONE", "LOG_LEVEL_ENCODING"} {
+		if _, ok := os.LookupEnv(name); ok && cfg.Encoding == nil {
+			cfg.Encoding = &EncodingConfig{}
+		}
+	}
+	if cfg.Encoding != nil {
+		setString("LOG_TIME_LAYOUT", &cfg.Encoding.TimeLayout)
+		setString("LOG_TIME_ZONE", &cfg.Encoding.TimeZone)
+		setString("LOG_LEVEL_ENCODING", &cfg.Encoding.LevelEncoding)
+	}
+	if value, ok := os.LookupEnv("LOG_TYPE"); ok {
+		if logType, ok := logTypes[strings.ToLower(strings.TrimSpace(value))]; ok {
+			cfg.LogType = logType
+		} else {
+			setInt("LOG_TYPE", &cfg.LogType)
+		}
+	}
+	return errors.Join(errs...)
+}
+
+// Validate returns the errors of all invalid fields.
+func (cfg *Config) Validate() error {
+	var errs []error
+	check := func(field string, err error) {
+		if err != nil {
+			errs = append(errs, fmt.Errorf("%s: %w", field, err))
+		}
+	}
+
+	check("log_level", validateLevel(cfg.LogLevel))
+	check("log_format", validateFormat(cfg.LogFormat))
+	check("log_rotate", validateRotate(cfg.LogRotate))
+	check("log_file_max_size", validateNotNegative(cfg.LogFileMaxSize))
+	check("log_file_max_backups", validateNotNegative(cfg.LogFileMaxBackups))
+	check("log_max_age", validateNotNegative(cfg.LogMaxAge))
+	if _, _, err := parseModuleLevels(cfg.LogModuleLevels); err != nil {
+		check("log_module_levels", err)
+	}
+	if len(cfg.Outputs) == 0 {
+		if cfg.LogType < LogStdout || cfg.LogType > LogStdoutAndFile {
+			check("log_type", fmt.Errorf("unknown log type %d", cfg.LogType))
+		}
+	}
+
+	for i, out := range cfg.Outputs {
+		field := fmt.Sprintf("outputs[%d]", i)
+		switch out.Type {
+		case OutputStdout, OutputStderr, OutputFile, OutputJournald:
+		case OutputAudit:
+			if out.Async != nil {
+				check(field+".async", errors.New("audit output can not be async"))
+			}
+		case OutputHTTP:
+			if out.HTTP == nil {
+				check(field+".http", errors.New("http output without http config"))
+			} else {
+				check(field+".http", validateHTTP(*out.HTTP))
+			}
+		case OutputSyslog:
+			if out.Syslog != nil {
+				check(field+".syslog", validateSyslog(*out.Syslog))
+			}
+		case OutputWriter:
+			if out.Writer == nil {
+				check(field+".writer", errors.New("writer output without writer"))
+			}
+		default:
+			check(field+".type", fmt.Errorf("unknown output type %q", out.Type))
+		}
+		if out.Level != "" {
+			check(field+".level", validateLevel(out.Level))
+		}
+		check(field+".format", validateFormat(out.Format))
+		check(field+".rotate", validateRotate(out.Rotate))
+		check(field+".max_size", validateNotNegative(out.MaxSize))
+		check(field+".max_backups", validateNotNegative(out.MaxBackups))
+		check(field+".max_age", validateNotNegative(out.MaxAge))
+		if out.Async != nil {
+			check(field+".async", validateAsync(*out.Async))
+		}
+		if out.Encoding != nil {
+			_, err := out.Encoding.encoderConfig()
+			check(field+".encoding", err)
+		}
+		if out.Encrypt != nil {
+			rotate := out.Rotate
+			if rotate == "" {
+				rotate = cfg.LogRotate
+			}
+			if out.Type != OutputFile {
+				check(field+".encrypt", fmt.Errorf("%s output can not be encrypted", out.Type))
+			} else if rotate != RotateDaily && rotate != RotateHourly {
+				check(field+".encrypt", errors.New("encrypted file output must be rotated daily or hourly"))
+			} else {
+				check(field+".encrypt", validateEncrypt(*out.Encrypt))
+			}
+		}
+	}
+
+	if cfg.Async != nil {
+		check("async", validateAsync(*cfg.Async))
+	}
+	if cfg.Redact != nil {
+		_, err := NewRedactor(*cfg.Redact)
+		check("redact", err)
+	}
+	for i, sampling := range cfg.Sampling {
+		field := fmt.Sprintf("sampling[%d]", i)
+		if sampling.Level != "" {
+			check(field+".level", validateLevel(sampling.Level))
+		}
+		check(field+".initial", validateNotNegative(sampling.Initial))
+		check(field+".thereafter", validateNotNegative(sampling.Thereafter))
+	}
+	for i, rateLimit := range cfg.RateLimit {
+		field := fmt.Sprintf("rate_limit[%d]", i)
+		if rateLimit.Level != "" {
+			check(field+".level", validateLevel(rateLimit.Level))
+		}
+		if rateLimit.Limit <= 0 {
+			check(field+".limit", fmt.Errorf("must be positive, got %d", rateLimit.Limit))
+		}
+	}
+	if cfg.Encoding != nil {
+		_, err := cfg.Encoding.encoderConfig()
+		check("encoding", err)
+	}
+	check("caller_skip", validateNotNegative(cfg.CallerSkip))
+	if cfg.StacktraceLevel != "" {
+		check("stacktrace_level", validateLevel(cfg.StacktraceLevel))
+	}
+	if cfg.Hook != nil {
+		if cfg.Hook.Level != "" {
+			check("hook.level", validateLevel(cfg.Hook.Level))
+		}
+		check("hook.queue_size", validateNotNegative(cfg.Hook.QueueSize))
+		if cfg.Hook.Webhook != nil && cfg.Hook.Webhook.URL == "" {
+			check("hook.webhook.url", errors.New("url is required"))
+		}
+	}
+	return errors.Join(errs...)
+}
+
+func validateLevel(level string) error {
+	_, err := parseLevel(level)
+	return err
+}
+
+func validateFormat(format string) error {
+	switch format {
+	case "", "console", "logfmt", "json", "pretty":
+		return nil
+	}
+	return fmt.Errorf("unknown log format %q, expect console, logfmt, json or pretty", format)
+}
+
+func validateRotate(rotate string) error {
+	switch rotate {
+	case "", RotateSize, RotateDaily, RotateHourly:
+		return nil
+	}
+	return fmt.Errorf("unknown rotate mode %q, expect size, daily or hourly", rotate)
+}
+
+func validateNotNegative(n int) error {
+	if n < 0 {
+		return fmt.Errorf("must not be negative, got %d", n)
+	}
+	return nil
+}
+
+func validateSyslog(syslog SyslogConfig) error {
+	switch syslog.Protocol {
+	case "", SyslogRFC5424, SyslogRFC3164:
+	default:
+		return fmt.Errorf("unknown syslog protocol %q", syslog.Protocol)
+	}
+	if _, ok := syslogFacilities[strings.ToLower(syslog.Facility)]; syslog.Facility != "" && !ok {
+		return fmt.Errorf("unknown syslog facility %q", syslog.Facility)
+	}
+	return nil
+}
+
+func validateHTTP(cfg HTTPConfig) error {
+	if cfg.URL == "" {
+		return errors.New("url is required")
+	}
+	switch cfg.Format {
+	case "", HTTPFormatJSONLines, HTTPFormatLoki, HTTPFormatElasticsearch:
+		return nil
+	}
+	return fmt.Errorf("unknown http format %q", cfg.Format)
+}
+
+func validateAsync(async AsyncConfig) error {
+	switch async.Overflow {
+	case "", OverflowBlock, OverflowDropOldest, OverflowDropNewest:
+	default:
+		return fmt.Errorf("unknown overflow policy %q", async.Overflow)
+	}
+	return validateNotNegative(async.BufferSize)
+}
+
+// Apply set the root level and module levels of cfg, module levels not in
+// cfg are removed.
+func (m *ModuleLevels) Apply(cfg *Config) error {
+	root, err := parseLevel(cfg.LogLevel)
+	if err != nil {
+		return err
+	}
+	specRoot, modules, err := parseModuleLevels(cfg.LogModuleLevels)
+	if err != nil {
+		return err
+	}
+	if specRoot != nil {
+		root = *specRoot
+	}
+
+	m.root.SetLevel(root)
+	m.update(func(current map[string]zapcore.Level) {
+		for name := range current {
+			delete(current, name)
+		}
+		for name, level := range modules {
+			if level != nil {
+				current[name] = *level
+			}
+		}
+	})
+	return nil
+}
+
+// defaultWatchInterval the poll interval of WatchConfig when interval is not
+// positive.
+const defaultWatchInterval = 10 * time.Second
+
+// WatchConfig poll the config file every interval until ctx is done, onChange
+// is called with the loaded config or the error when the file changes. The
+// interval defaults to 10s when it is not positive. See WatchLevels to apply
+// the levels live.
+func WatchConfig(ctx context.Context, path string, interval time.Duration, onChange func(cfg *Config, err error)) {
+	if interval <= 0 {
+		interval = defaultWatchInterval
+	}
+	last, _ := os.Stat(path)
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+		}
+
+		info, err := os.Stat(path)
+		if err != nil {
+			if last != nil {
+				onChange(nil, err)
+			}
+			last = nil
+			continue
+		}
+		if last != nil && info.ModTime().Equal(last.ModTime()) && info.Size() == last.Size() {
+			continue
+		}
+		last = info
+		onChange(LoadConfig(path))
+	}
+}
+
+// WatchLevels watch the config file like WatchConfig and apply its log level
+// and module levels to levels, the ModuleLevels given to New by
+// WithModuleLevels or Config.ModuleLevels. onError is called with the load
+// errors when not nil, levels are kept then. The other settings such as the
+// outputs and sampling need a new logger and are not applied.
+//
+//	levels := logutil.NewModuleLevels(zap.NewAtomicLevel())
+//	l, err := logutil.New(cfg, logutil.WithModuleLevels(levels))
+//	go logutil.WatchLevels(ctx, path, 10*time.Second, levels, nil)
+func WatchLevels(ctx context.Context, path string, interval time.Duration, levels *ModuleLevels, onError func(error)) {
+	WatchConfig(ctx, path, interval, func(cfg *Config, err error) {
+		if err == nil {
+			err = levels.Apply(cfg)
+		}
+		if err != nil && onError != nil {
+			onError(err)
+		}
+	})
+}
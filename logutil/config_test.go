@@ -0,0 +1,156 @@
+package logutil
+
+import (
+	"context"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestLoadConfig(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	data := `
+log_level: warn
+log_format: logfmt
+log_module_levels: db=debug
+outputs:
+  - type: stdout
+    level: info
+async:
+  buffer_size: 16
+  flush_interval: 2s
+rate_limit:
+  - limit: 10
+    interval: 30s
+`
+	err := os.WriteFile(path, []byte(data), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	t.Setenv("LOG_LEVEL", "error")
+	t.Setenv("LOG_TYPE", "stdout_and_file")
+
+	cfg, err := LoadConfig(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if cfg.LogLevel != "error" || cfg.LogFormat != "logfmt" || cfg.LogType != LogStdoutAndFile {
+		t.Fatalf("unexpected config %+v", cfg)
+	}
+	if cfg.LogFileName != defaultConfig.LogFileName {
+		t.Fatalf("default file name not kept, got %q", cfg.LogFileName)
+	}
+	if cfg.Async.FlushInterval != 2*time.Second || cfg.RateLimit[0].Interval != 30*time.Second {
+		t.Fatalf("unexpected durations %+v %+v", cfg.Async, cfg.RateLimit)
+	}
+
+	levels := NewModuleLevels(zap.NewAtomicLevel())
+	levels.SetLevel("http", 0)
+	err = levels.Apply(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels.String() != "error,db=debug" {
+		t.Fatalf("unexpected levels %q", levels.String())
+	}
+}
+
+func TestLoadConfigErrors(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.json")
+	err := os.WriteFile(path, []byte(`{"log_level": "loud", "log_format": "xml", "unknown": 1}`), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = LoadConfigFile(path)
+	if err == nil || !strings.Contains(err.Error(), "unknown") {
+		t.Fatalf("expected unknown field error, got %v", err)
+	}
+
+	cfg := &Config{LogLevel: "loud", LogFormat: "xml", LogFileMaxSize: -1}
+	err = cfg.Validate()
+	for _, want := range []string{"log_level", "log_format", "log_file_max_size"} {
+		if err == nil || !strings.Contains(err.Error(), want) {
+			t.Fatalf("expected %s error, got %v", want, err)
+		}
+	}
+
+	t.Setenv("LOG_FILE_MAX_SIZE", "abc")
+	_, err = LoadConfig("")
+	if err == nil || !strings.Contains(err.Error(), `LOG_FILE_MAX_SIZE: invalid integer "abc"`) {
+		t.Fatalf("expected env error, got %v", err)
+	}
+}
+
+// replaceFile replace path by rename, so the watcher never reads it half
+// written.
+func replaceFile(path, data string) error {
+	tmp := path + ".tmp"
+	if err := os.WriteFile(tmp, []byte(data), 0o644); err != nil {
+		return err
+	}
+	return os.Rename(tmp, path)
+}
+
+func TestWatchLevels(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "log.yaml")
+	err := os.WriteFile(path, []byte("log_level: info\n"), 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	core, logs := observer.New(zap.DebugLevel)
+	levels := NewModuleLevels(zap.NewAtomicLevelAt(zapcore.InfoLevel))
+	l := zap.New(newModuleCore(core, levels))
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+	errs := make(chan error, 10)
+	go WatchLevels(ctx, path, 10*time.Millisecond, levels, func(err error) { errs <- err })
+	// let the watcher stat the first version.
+	time.Sleep(50 * time.Millisecond)
+
+	err = replaceFile(path, "log_level: warn\nlog_module_levels: db=debug\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 100 && levels.String() != "warn,db=debug"; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+	if levels.String() != "warn,db=debug" {
+		t.Fatalf("levels not applied, got %q", levels.String())
+	}
+	l.Info("root info")
+	l.Named("db").Debug("db debug")
+	if got := messages(logs); got != "db debug" {
+		t.Fatalf("got %q", got)
+	}
+
+	// invalid configs are reported and the levels are kept.
+	err = replaceFile(path, "log_level: verbose\n")
+	if err != nil {
+		t.Fatal(err)
+	}
+	select {
+	case err := <-errs:
+		if !strings.Contains(err.Error(), "verbose") {
+			t.Fatalf("unexpected error %v", err)
+		}
+	case <-time.After(time.Second):
+		t.Fatal("error not reported")
+	}
+	if levels.String() != "warn,db=debug" {
+		t.Fatalf("invalid config changed levels %q", levels.String())
+	}
+}
+
+func TestWatchConfigDefaultInterval(t *testing.T) {
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	// a non-positive interval does not panic.
+	WatchConfig(ctx, filepath.Join(t.TempDir(), "log.yaml"), 0, func(*Config, error) {})
+}
@@ -0,0 +1,177 @@
+package logutil
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"net/http"
+	"strings"
+
+	"go.uber.org/zap"
+)
+
+// http headers used by ContextMiddleware.
+const (
+	RequestIDHeader   = "X-Request-ID"
+	TraceparentHeader = "traceparent"
+)
+
+// maxRequestIDLen the max length of the request ids accepted from clients.
+const maxRequestIDLen = 128
+
+type ctxLoggerKey struct{}
+
+type ctxRequestIDKey struct{}
+
+// ctxLogger the logger and the fields added after it in the context.
+type ctxLogger struct {
+	logger *zap.Logger // logger of WithContext, nil for zap.L()
+	fields []zap.Field
+	with   *zap.Logger // logger with fields built once, nil when logger is nil
+}
+
+// WithContext returns a copy of ctx carrying the logger, fields added to ctx
+// by WithFields before are dropped.
+func WithContext(ctx context.Context, l *zap.Logger) context.Context {
+	return context.WithValue(ctx, ctxLoggerKey{}, &ctxLogger{logger: l, with: l})
+}
+
+// WithFields returns a copy of ctx with fields added to its logger.
+func WithFields(ctx context.Context, fields ...zap.Field) context.Context {
+	if len(fields) == 0 {
+		return ctx
+	}
+	next := &ctxLogger{}
+	if cl, ok := ctx.Value(ctxLoggerKey{}).(*ctxLogger); ok {
+		next.logger = cl.logger
+		next.fields = append(next.fields, cl.fields...)
+		if cl.with != nil {
+			next.with = cl.with.With(fields...)
+		}
+	}
+	next.fields = append(next.fields, fields...)
+	return context.WithValue(ctx, ctxLoggerKey{}, next)
+}
+
+// FromContext returns the logger of ctx with its fields, zap.L() is used when
+// ctx has no logger.
+func FromContext(ctx context.Context) *zap.Logger {
+	cl, ok := ctx.Value(ctxLoggerKey{}).(*ctxLogger)
+	if !ok {
+		return zap.L()
+	}
+	if cl.with != nil {
+		return cl.with
+	}
+	// zap.L() may be replaced, the fields are added at every call.
+	l := zap.L()
+	if len(cl.fields) > 0 {
+		l = l.With(cl.fields...)
+	}
+	return l
+}
+
+// ParseTraceparent parse the W3C traceparent header, e.g.
+// 00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01.
+func ParseTraceparent(header string) (traceID, spanID string, ok bool) {
+	parts := strings.Split(strings.TrimSpace(header), "-")
+	if len(parts) < 4 {
+		return "", "", false
+	}
+	version, traceID, spanID, flags := parts[0], parts[1], parts[2], parts[3]
+	if !isLowerHex(version, 2) || version == "ff" || (version == "00" && len(parts) != 4) {
+		return "", "", false
+	}
+	if !isLowerHex(traceID, 32) || traceID == strings.Repeat("0", 32) {
+		return "", "", false
+	}
+	if !isLowerHex(spanID, 16) || spanID == strings.Repeat("0", 16) {
+		return "", "", false
+	}
+	if !isLowerHex(flags, 2) {
+		return "", "", false
+	}
+	return traceID, spanID, true
+}
+
+// WithTraceparent returns a copy of ctx with trace_id and span_id fields of
+// the traceparent header, ctx is returned as is when the header is invalid.
+func WithTraceparent(ctx context.Context, header string) context.Context {
+	traceID, spanID, ok := ParseTraceparent(header)
+	if !ok {
+		return ctx
+	}
+	return WithFields(ctx, zap.String("trace_id", traceID), zap.String("span_id", spanID))
+}
+
+// WithRequestID returns a copy of ctx carrying the request id.
+func WithRequestID(ctx context.Context, requestID string) context.Context {
+	return context.WithValue(ctx, ctxRequestIDKey{}, requestID)
+}
+
+// NewRequestID returns a random request id.
+func NewRequestID() string {
+	b := make([]byte, 16)
+	_, _ = rand.Read(b)
+	return hex.EncodeToString(b)
+}
+
+// ValidRequestID reports whether the request id from a client can be logged
+// and echoed, it has at most 128 characters of [A-Za-z0-9._-].
+func ValidRequestID(id string) bool {
+	if id == "" || len(id) > maxRequestIDLen {
+		return false
+	}
+	for i := 0; i < len(id); i++ {
+		c := id[i]
+		if !('a' <= c && c <= 'z' || 'A' <= c && c <= 'Z' || '0' <= c && c <= '9' || c == '.' || c == '_' || c == '-') {
+			return false
+		}
+	}
+	return true
+}
+
+// RequestIDFromContext returns the request id set by ContextMiddleware.
+func RequestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(ctxRequestIDKey{}).(string)
+	return id
+}
+
+// ContextMiddleware inject a request scoped logger into the request context
+// with request_id, method, path and the trace ids of the traceparent header.
+// The request id is taken from the X-Request-ID header, or generated when it
+// is missing or not valid by ValidRequestID, and is set to the response header.
+func ContextMiddleware(l *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			requestID := r.Header.Get(RequestIDHeader)
+			if !ValidRequestID(requestID) {
+				requestID = NewRequestID()
+			}
+			w.Header().Set(RequestIDHeader, requestID)
+
+			ctx := WithRequestID(r.Context(), requestID)
+			ctx = WithContext(ctx, l)
+			ctx = WithFields(ctx,
+				zap.String("request_id", requestID),
+				zap.String("method", r.Method),
+				zap.String("path", r.URL.Path),
+			)
+			ctx = WithTraceparent(ctx, r.Header.Get(TraceparentHeader))
+			next.ServeHTTP(w, r.WithContext(ctx))
+		})
+	}
+}
+
+func isLowerHex(s string, n int) bool {
+	if len(s) != n {
+		return false
+	}
+	for i := 0; i < len(s); i++ {
+		c := s[i]
+		if (c < '0' || c > '9') && (c < 'a' || c > 'f') {
+			return false
+		}
+	}
+	return true
+}
@@ -0,0 +1,115 @@
+package logutil
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestParseTraceparent(t *testing.T) {
+	tests := []struct {
+		header string
+		ok     bool
+	}{
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", true},
+		{"01-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-future", true},
+		{"00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01-extra", false},
+		{"ff-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01", false},
+		{"00-00000000000000000000000000000000-00f067aa0ba902b7-01", false},
+		{"00-4BF92F3577B34DA6A3CE929D0E0E4736-00f067aa0ba902b7-01", false},
+		{"", false},
+	}
+	for _, tt := range tests {
+		traceID, spanID, ok := ParseTraceparent(tt.header)
+		if ok != tt.ok {
+			t.Errorf("ParseTraceparent(%q) ok = %v, want %v", tt.header, ok, tt.ok)
+		}
+		if ok && (traceID != "4bf92f3577b34da6a3ce929d0e0e4736" || spanID != "00f067aa0ba902b7") {
+			t.Errorf("ParseTraceparent(%q) = %s %s", tt.header, traceID, spanID)
+		}
+	}
+}
+
+func TestContextMiddleware(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	handler := ContextMiddleware(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ctx := WithFields(r.Context(), zap.String("user", "bob"))
+		FromContext(ctx).Info("handled")
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/users/1", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	req.Header.Set(TraceparentHeader, "00-4bf92f3577b34da6a3ce929d0e0e4736-00f067aa0ba902b7-01")
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+
+	if rec.Header().Get(RequestIDHeader) != "req-1" {
+		t.Errorf("response request id %q", rec.Header().Get(RequestIDHeader))
+	}
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	want := map[string]string{
+		"request_id": "req-1",
+		"method":     http.MethodGet,
+		"path":       "/users/1",
+		"trace_id":   "4bf92f3577b34da6a3ce929d0e0e4736",
+		"span_id":    "00f067aa0ba902b7",
+		"user":       "bob",
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Errorf("field %s = %v, want %s", key, fields[key], value)
+		}
+	}
+}
+
+func TestContextMiddlewareInvalidRequestID(t *testing.T) {
+	handler := ContextMiddleware(zap.NewNop())(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	for _, id := range []string{"req 1", "req\n1", `req"1`, strings.Repeat("a", maxRequestIDLen+1)} {
+		req := httptest.NewRequest(http.MethodGet, "/", nil)
+		req.Header.Set(RequestIDHeader, id)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		got := rec.Header().Get(RequestIDHeader)
+		if got == id || !ValidRequestID(got) {
+			t.Errorf("request id %q replaced by %q", id, got)
+		}
+	}
+	if !ValidRequestID("Req_1.a-" + strings.Repeat("b", maxRequestIDLen-8)) {
+		t.Error("valid request id rejected")
+	}
+}
+
+func TestFromContext(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	ctx := WithContext(context.Background(), zap.New(core))
+	ctx = WithFields(ctx, zap.String("request_id", "req-1"))
+	ctx = WithFields(ctx, zap.String("user", "bob"))
+
+	// the logger with the fields is built once.
+	l := FromContext(ctx)
+	if FromContext(ctx) != l {
+		t.Fatal("logger rebuilt on lookup")
+	}
+	l.Info("hello")
+	fields := logs.All()[0].ContextMap()
+	if fields["request_id"] != "req-1" || fields["user"] != "bob" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+
+	// without a logger zap.L() is used when logging.
+	globalCore, globalLogs := observer.New(zap.DebugLevel)
+	defer zap.ReplaceGlobals(zap.New(globalCore))()
+	FromContext(WithFields(context.Background(), zap.String("user", "bob"))).Info("global")
+	if globalLogs.FilterField(zap.String("user", "bob")).Len() != 1 {
+		t.Fatal("fields not added to zap.L()")
+	}
+}
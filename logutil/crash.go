@@ -0,0 +1,51 @@
+//go:build go1.23
+
+package logutil
+
+import (
+	"errors"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+
+	"go.uber.org/zap"
+)
+
+// CaptureCrashes make the runtime write the fatal error and stacks of an
+// unrecovered panic to path in addition to stderr, they would not reach the
+// log files otherwise. Call it at startup, the crash of the previous run
+// found at path is logged at error by l and the file is renamed with its
+// modification time.
+func CaptureCrashes(l *zap.Logger, path string) error {
+	err := os.MkdirAll(filepath.Dir(path), 0o755)
+	if err != nil {
+		return err
+	}
+	info, err := os.Stat(path)
+	if err == nil && info.Size() > 0 {
+		data, err := os.ReadFile(path)
+		if err != nil {
+			return err
+		}
+		previous := path + "." + info.ModTime().Format("20060102T150405")
+		err = os.Rename(path, previous)
+		if err != nil {
+			return err
+		}
+		l.Error("previous run crashed",
+			zap.Time("crash_time", info.ModTime()),
+			zap.String("crash_report", previous),
+			zap.String("stack", string(data)),
+		)
+	} else if err != nil && !errors.Is(err, os.ErrNotExist) {
+		return err
+	}
+
+	f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	// the runtime dups the file, so it can be closed.
+	defer f.Close()
+	return debug.SetCrashOutput(f, debug.CrashOptions{})
+}
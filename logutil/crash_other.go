@@ -0,0 +1,15 @@
+//go:build !go1.23
+
+package logutil
+
+import (
+	"errors"
+
+	"go.uber.org/zap"
+)
+
+// CaptureCrashes needs runtime/debug.SetCrashOutput of go1.23, it returns an
+// error when built with older go versions.
+func CaptureCrashes(l *zap.Logger, path string) error {
+	return errors.New("CaptureCrashes requires go1.23 or later")
+}
@@ -0,0 +1,54 @@
+//go:build go1.23
+
+package logutil
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+	"runtime/debug"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestCaptureCrashes(t *testing.T) {
+	path := filepath.Join(t.TempDir(), "crash.log")
+	if os.Getenv("LOGUTIL_CRASH_PATH") != "" {
+		err := CaptureCrashes(zap.NewNop(), os.Getenv("LOGUTIL_CRASH_PATH"))
+		if err != nil {
+			t.Fatal(err)
+		}
+		panic("unrecovered boom")
+	}
+
+	cmd := exec.Command(os.Args[0], "-test.run=^TestCaptureCrashes$")
+	cmd.Env = append(os.Environ(), "LOGUTIL_CRASH_PATH="+path)
+	if err := cmd.Run(); err == nil {
+		t.Fatal("expected the process to crash")
+	}
+
+	core, logs := observer.New(zap.DebugLevel)
+	err := CaptureCrashes(zap.New(core), path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer func() { _ = debug.SetCrashOutput(nil, debug.CrashOptions{}) }()
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Message != "previous run crashed" {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+	fields := entries[0].ContextMap()
+	if stack, _ := fields["stack"].(string); !strings.Contains(stack, "panic: unrecovered boom") {
+		t.Fatalf("unexpected stack %v", fields["stack"])
+	}
+	if _, err := os.Stat(fields["crash_report"].(string)); err != nil {
+		t.Fatal(err)
+	}
+	if info, err := os.Stat(path); err != nil || info.Size() != 0 {
+		t.Fatalf("crash file not reset %v", err)
+	}
+}
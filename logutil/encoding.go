@@ -0,0 +1,172 @@
+package logutil
+
+import (
+	"fmt"
+	"strings"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// Time layouts of EncodingConfig, custom Go time layouts have the
+// TimeLayoutPrefix, e.g. "layout:2006-01-02 15:04:05.000".
+const (
+	TimeISO8601     = "iso8601"
+	TimeRFC3339     = "rfc3339"
+	TimeRFC3339Nano = "rfc3339nano"
+	TimeEpoch       = "epoch"
+	TimeEpochMillis = "epochmillis"
+	TimeEpochNanos  = "epochnanos"
+
+	TimeLayoutPrefix = "layout:"
+)
+
+// Level encodings of EncodingConfig.
+const (
+	LevelCapital      = "capital"
+	LevelLowercase    = "lowercase"
+	LevelCapitalColor = "capitalcolor"
+	LevelColor        = "color"
+)
+
+// default keys of the encoders.
+const (
+	timeKey       = "ts"
+	levelKey      = "level"
+	nameKey       = "logger"
+	callerKey     = "caller"
+	messageKey    = "msg"
+	stacktraceKey = "stacktrace"
+)
+
+// OmitKey the key of the fields not written.
+const OmitKey = "-"
+
+var timeEncoders = map[string]zapcore.TimeEncoder{
+	TimeISO8601:     zapcore.ISO8601TimeEncoder,
+	TimeRFC3339:     zapcore.RFC3339TimeEncoder,
+	TimeRFC3339Nano: zapcore.RFC3339NanoTimeEncoder,
+	TimeEpoch:       zapcore.EpochTimeEncoder,
+	TimeEpochMillis: zapcore.EpochMillisTimeEncoder,
+	TimeEpochNanos:  zapcore.EpochNanosTimeEncoder,
+}
+
+var levelEncoders = map[string]zapcore.LevelEncoder{
+	LevelCapital:      zapcore.CapitalLevelEncoder,
+	LevelLowercase:    zapcore.LowercaseLevelEncoder,
+	LevelCapitalColor: zapcore.CapitalColorLevelEncoder,
+	LevelColor:        zapcore.LowercaseColorLevelEncoder,
+}
+
+// EncodingKeys the keys of the entry fields, empty keys use the defaults and
+// OmitKey omits the field, e.g. @timestamp, log.level and message for ECS.
+type EncodingKeys struct {
+	Time       string `json:"time" yaml:"time"`             // 时间字段名, 默认 ts
+	Level      string `json:"level" yaml:"level"`           // 级别字段名, 默认 level
+	Logger     string `json:"logger" yaml:"logger"`         // logger 名称字段名, 默认 logger
+	Caller     string `json:"caller" yaml:"caller"`         // 调用位置字段名, 默认 caller
+	Message    string `json:"message" yaml:"message"`       // 消息字段名, 默认 msg
+	Stacktrace string `json:"stacktrace" yaml:"stacktrace"` // 堆栈字段名, 默认 stacktrace
+}
+
+// EncodingConfig encoding config of the time, level and keys.
+type EncodingConfig struct {
+	TimeLayout    string       `json:"time_layout" yaml:"time_layout"`       // 时间格式 iso8601 rfc3339 rfc3339nano epoch epochmillis epochnanos 或 layout:<Go layout>, 默认 iso8601
+	TimeZone      string       `json:"time_zone" yaml:"time_zone"`           // 时区, 如 UTC Asia/Shanghai, 默认本地时区
+	LevelEncoding string       `json:"level_encoding" yaml:"level_encoding"` // 级别格式 capital lowercase capitalcolor color, 默认 capital
+	Keys          EncodingKeys `json:"keys" yaml:"keys"`                     // 字段名
+}
+
+// withDefaults returns the keys with the empty keys set to the defaults.
+func (k EncodingKeys) withDefaults() EncodingKeys {
+	setDefault := func(key *string, value string) {
+		if *key == "" {
+			*key = value
+		}
+	}
+	setDefault(&k.Time, timeKey)
+	setDefault(&k.Level, levelKey)
+	setDefault(&k.Logger, nameKey)
+	setDefault(&k.Caller, callerKey)
+	setDefault(&k.Message, messageKey)
+	setDefault(&k.Stacktrace, stacktraceKey)
+	return k
+}
+
+// location returns the time zone, nil means local.
+func (e *EncodingConfig) location() (*time.Location, error) {
+	if e == nil || e.TimeZone == "" {
+		return nil, nil
+	}
+	loc, err := time.LoadLocation(e.TimeZone)
+	if err != nil {
+		return nil, fmt.Errorf("unknown time zone %q", e.TimeZone)
+	}
+	return loc, nil
+}
+
+// customLayout returns the Go layout of a TimeLayout with TimeLayoutPrefix.
+func (e *EncodingConfig) customLayout() (string, bool) {
+	if e == nil || !strings.HasPrefix(e.TimeLayout, TimeLayoutPrefix) {
+		return "", false
+	}
+	return strings.TrimPrefix(e.TimeLayout, TimeLayoutPrefix), true
+}
+
+// encoderConfig returns the zap encoder config, nil e returns the default.
+func (e *EncodingConfig) encoderConfig() (zapcore.EncoderConfig, error) {
+	if e == nil {
+		e = &EncodingConfig{}
+	}
+	encoderConfig := zap.NewProductionEncoderConfig()
+
+	timeEncoder := zapcore.ISO8601TimeEncoder // log 时间格式 例如: 2021-09-11t20:05:54.852+0800
+	if layout, ok := e.customLayout(); ok {
+		timeEncoder = zapcore.TimeEncoderOfLayout(layout)
+	} else if e.TimeLayout != "" {
+		timeEncoder, ok = timeEncoders[strings.ToLower(e.TimeLayout)]
+		if !ok {
+			return encoderConfig, fmt.Errorf("unknown time layout %q, custom layouts need the %q prefix", e.TimeLayout, TimeLayoutPrefix)
+		}
+	}
+	loc, err := e.location()
+	if err != nil {
+		return encoderConfig, err
+	}
+	if loc != nil {
+		inner := timeEncoder
+		timeEncoder = func(t time.Time, enc zapcore.PrimitiveArrayEncoder) {
+			inner(t.In(loc), enc)
+		}
+	}
+	encoderConfig.EncodeTime = timeEncoder
+
+	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder // 输出level序列化为全大写字符串，如 INFO DEBUG ERROR
+	if e.LevelEncoding != "" {
+		levelEncoder, ok := levelEncoders[strings.ToLower(e.LevelEncoding)]
+		if !ok {
+			return encoderConfig, fmt.Errorf("unknown level encoding %q, expect capital, lowercase, capitalcolor or color", e.LevelEncoding)
+		}
+		encoderConfig.EncodeLevel = levelEncoder
+	}
+
+	keys := e.Keys.withDefaults()
+	for _, k := range []struct {
+		key   *string
+		value string
+	}{
+		{&encoderConfig.TimeKey, keys.Time},
+		{&encoderConfig.LevelKey, keys.Level},
+		{&encoderConfig.NameKey, keys.Logger},
+		{&encoderConfig.CallerKey, keys.Caller},
+		{&encoderConfig.MessageKey, keys.Message},
+		{&encoderConfig.StacktraceKey, keys.Stacktrace},
+	} {
+		*k.key = k.value
+		if k.value == OmitKey {
+			*k.key = zapcore.OmitKey
+		}
+	}
+	return encoderConfig, nil
+}
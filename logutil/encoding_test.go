@@ -0,0 +1,113 @@
+package logutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"path/filepath"
+	"runtime"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+// logWrapped logs through a wrapper, the caller should be its caller.
+func logWrapped(l *zap.Logger, msg string) {
+	l.Error(msg)
+}
+
+func TestEncoding(t *testing.T) {
+	var buf bytes.Buffer
+	encoding := EncodingConfig{
+		TimeLayout:    TimeEpochMillis,
+		TimeZone:      "UTC",
+		LevelEncoding: LevelLowercase,
+		Keys:          EncodingKeys{Time: "@timestamp", Level: "log.level", Message: "message", Logger: OmitKey},
+	}
+	l, err := New(&Config{
+		LogLevel:        "info",
+		LogFormat:       "json",
+		Caller:          true,
+		Outputs:         []Output{{Type: OutputWriter, Writer: &buf}},
+		Encoding:        &encoding,
+		CallerSkip:      1,
+		StacktraceLevel: "error",
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	before := time.Now().UnixMilli()
+	logWrapped(l.Named("db"), "failed")
+	_, _, line, _ := runtime.Caller(0)
+
+	var m map[string]interface{}
+	err = json.Unmarshal(buf.Bytes(), &m)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if m["message"] != "failed" || m["log.level"] != "error" || m["logger"] != nil {
+		t.Fatalf("unexpected keys %v", m)
+	}
+	if ts, _ := m["@timestamp"].(float64); int64(ts) < before {
+		t.Fatalf("unexpected time %v", m["@timestamp"])
+	}
+	if caller := fmt.Sprintf("logutil/encoding_test.go:%d", line-1); m["caller"] != caller {
+		t.Fatalf("unexpected caller %v", m["caller"])
+	}
+	if stack, _ := m["stacktrace"].(string); !strings.Contains(stack, "TestEncoding") {
+		t.Fatalf("unexpected stacktrace %v", m["stacktrace"])
+	}
+
+	dir := t.TempDir()
+	custom := EncodingConfig{TimeLayout: "layout:2006-01-02 15:04:05.000", TimeZone: "Asia/Shanghai", Keys: EncodingKeys{Message: "message"}}
+	l, err = New(&Config{LogFormat: "json", LogPath: dir, LogFileName: "app.log", LogType: LogFile}, WithEncoding(custom))
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("custom")
+	_ = lastSinks.Load().close()
+
+	r, err := NewReader(filepath.Join(dir, "app.log"), Filter{Since: time.Now().Add(-time.Minute)})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.Encoding = &custom
+	var entries []Entry
+	err = r.Read(func(ent Entry) bool {
+		entries = append(entries, ent)
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(entries) != 1 || entries[0].Message != "custom" || time.Since(entries[0].Time) > time.Minute {
+		t.Fatalf("unexpected entries %+v", entries)
+	}
+}
+
+func TestEncodingValidate(t *testing.T) {
+	cfg := &Config{
+		LogLevel:        "info",
+		Encoding:        &EncodingConfig{TimeZone: "Mars/Base", LevelEncoding: "upper"},
+		CallerSkip:      -1,
+		StacktraceLevel: "loud",
+	}
+	err := cfg.Validate()
+	if err == nil {
+		t.Fatal("expected error")
+	}
+	for _, msg := range []string{"encoding: unknown time zone", "caller_skip: must not be negative", "stacktrace_level: unknown log level"} {
+		if !strings.Contains(err.Error(), msg) {
+			t.Fatalf("%q not in %v", msg, err)
+		}
+	}
+
+	// a misspelled name is not taken as a custom layout.
+	cfg = &Config{LogLevel: "info", Encoding: &EncodingConfig{TimeLayout: "rfc3339-nano"}}
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), `unknown time layout "rfc3339-nano"`) {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
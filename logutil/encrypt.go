@@ -0,0 +1,208 @@
+package logutil
+
+import (
+	"bufio"
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/rand"
+	"encoding/base64"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+
+	"github.com/booyangcc/utils/cryptoutil"
+)
+
+// encryptHeader the prefix of the header line starting a segment of an
+// encrypted file, it is followed by the data key wrapped by the RSA public
+// key in base64. The records of the segment are base64 lines of the nonce
+// and the AES-GCM sealed entries.
+const encryptHeader = "#logutil-aes-gcm "
+
+// dataKeySize the size of the AES-256 data keys.
+const dataKeySize = 32
+
+// EncryptConfig encrypted file output config. The output must be rotated
+// daily or hourly, the default size rotation is not supported: lumberjack
+// rotates and compresses the files itself, so a new segment with its data key
+// can not be started in the rotated file. Validate and New return an error
+// for it.
+type EncryptConfig struct {
+	PublicKey     string `json:"public_key" yaml:"public_key"`           // RSA 公钥 PEM (PKIX)
+	PublicKeyFile string `json:"public_key_file" yaml:"public_key_file"` // RSA 公钥文件, PublicKey 为空时使用
+}
+
+// publicKey returns the PEM public key.
+func (cfg EncryptConfig) publicKey() ([]byte, error) {
+	if cfg.PublicKey != "" {
+		return []byte(cfg.PublicKey), nil
+	}
+	if cfg.PublicKeyFile == "" {
+		return nil, errors.New("public key is empty")
+	}
+	return os.ReadFile(cfg.PublicKeyFile)
+}
+
+// validateEncrypt check the public key can wrap a data key.
+func validateEncrypt(cfg EncryptConfig) error {
+	publicKey, err := cfg.publicKey()
+	if err != nil {
+		return err
+	}
+	_, err = cryptoutil.RSAEncrypt(make([]byte, dataKeySize), publicKey)
+	return err
+}
+
+// segmentEncrypter encrypt the records of a segment with its data key.
+type segmentEncrypter struct {
+	aead cipher.AEAD
+}
+
+// newSegmentEncrypter generate a data key and write the header of the new
+// segment to w, it returns the bytes written.
+func newSegmentEncrypter(w io.Writer, publicKey []byte) (*segmentEncrypter, int, error) {
+	key := make([]byte, dataKeySize)
+	_, err := rand.Read(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	aead, err := newAEAD(key)
+	if err != nil {
+		return nil, 0, err
+	}
+	wrapped, err := cryptoutil.RSAEncrypt(key, publicKey)
+	if err != nil {
+		return nil, 0, fmt.Errorf("wrap data key: %w", err)
+	}
+	n, err := io.WriteString(w, encryptHeader+base64.StdEncoding.EncodeToString(wrapped)+"\n")
+	if err != nil {
+		return nil, n, err
+	}
+	return &segmentEncrypter{aead: aead}, n, nil
+}
+
+// seal returns the record line of p.
+func (e *segmentEncrypter) seal(p []byte) ([]byte, error) {
+	nonce := make([]byte, e.aead.NonceSize(), e.aead.NonceSize()+len(p)+e.aead.Overhead())
+	_, err := rand.Read(nonce)
+	if err != nil {
+		return nil, err
+	}
+	sealed := e.aead.Seal(nonce, nonce, p, nil)
+	line := make([]byte, base64.StdEncoding.EncodedLen(len(sealed))+1)
+	base64.StdEncoding.Encode(line, sealed)
+	line[len(line)-1] = '\n'
+	return line, nil
+}
+
+func newAEAD(key []byte) (cipher.AEAD, error) {
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	return cipher.NewGCM(block)
+}
+
+// segmentDecrypter decrypt the lines of an encrypted file in order, lines
+// before the first header are not encrypted.
+type segmentDecrypter struct {
+	privateKey []byte
+	aead       cipher.AEAD
+	err        error
+}
+
+// line call fn with the decrypted lines of line, it returns false when fn
+// stops or the line can not be decrypted, err is set in that case.
+func (d *segmentDecrypter) line(line []byte, fn func(line []byte) bool) bool {
+	trimmed := bytes.TrimRight(line, "\r\n")
+	switch {
+	case len(trimmed) == 0:
+		return true
+	case bytes.HasPrefix(trimmed, []byte(encryptHeader)):
+		wrapped, err := base64.StdEncoding.DecodeString(string(trimmed[len(encryptHeader):]))
+		if err != nil {
+			d.err = fmt.Errorf("invalid segment header: %w", err)
+			return false
+		}
+		key, err := cryptoutil.RSADecrypt(wrapped, d.privateKey)
+		if err != nil {
+			d.err = fmt.Errorf("unwrap data key: %w", err)
+			return false
+		}
+		d.aead, err = newAEAD(key)
+		if err != nil {
+			d.err = err
+			return false
+		}
+		return true
+	case d.aead == nil:
+		return fn(line)
+	}
+
+	sealed := make([]byte, base64.StdEncoding.DecodedLen(len(trimmed)))
+	n, err := base64.StdEncoding.Decode(sealed, trimmed)
+	if err != nil {
+		d.err = fmt.Errorf("invalid record: %w", err)
+		return false
+	}
+	sealed = sealed[:n]
+	if len(sealed) < d.aead.NonceSize() {
+		d.err = errors.New("invalid record: too short")
+		return false
+	}
+	nonce, sealed := sealed[:d.aead.NonceSize()], sealed[d.aead.NonceSize():]
+	plain, err := d.aead.Open(sealed[:0], nonce, sealed, nil)
+	if err != nil {
+		d.err = fmt.Errorf("decrypt record: %w", err)
+		return false
+	}
+
+	// a record may hold several lines written at once.
+	for len(plain) > 0 {
+		i := bytes.IndexByte(plain, '\n')
+		if i < 0 {
+			i = len(plain) - 1
+		}
+		if !fn(plain[:i+1]) {
+			return false
+		}
+		plain = plain[i+1:]
+	}
+	return true
+}
+
+type decryptReader struct {
+	br  *bufio.Reader
+	dec segmentDecrypter
+	buf bytes.Buffer
+	err error
+}
+
+// NewDecryptReader returns a reader of the plain lines of an encrypted log
+// file read from r, privateKey is the RSA private key PEM (PKCS1) of the
+// public key used to write it.
+func NewDecryptReader(r io.Reader, privateKey []byte) io.Reader {
+	return &decryptReader{br: bufio.NewReader(r), dec: segmentDecrypter{privateKey: privateKey}}
+}
+
+func (d *decryptReader) Read(p []byte) (int, error) {
+	for d.buf.Len() == 0 && d.err == nil {
+		line, err := d.br.ReadBytes('\n')
+		if len(line) > 0 && !d.dec.line(line, func(line []byte) bool {
+			d.buf.Write(line)
+			return true
+		}) {
+			d.err = d.dec.err
+			break
+		}
+		if err != nil {
+			d.err = err
+		}
+	}
+	if d.buf.Len() > 0 {
+		return d.buf.Read(p)
+	}
+	return 0, d.err
+}
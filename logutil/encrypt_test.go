@@ -0,0 +1,201 @@
+package logutil
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/booyangcc/utils/cryptoutil"
+	"go.uber.org/zap"
+)
+
+func TestEncryptedOutput(t *testing.T) {
+	privateKey, publicKey, err := cryptoutil.GenerateRSAKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	cfg := Config{
+		LogLevel:    "info",
+		LogFormat:   "json",
+		LogPath:     dir,
+		LogFileName: "app.log",
+		LogRotate:   RotateDaily,
+		Outputs:     []Output{{Type: OutputFile, Encrypt: &EncryptConfig{PublicKey: string(publicKey)}}},
+	}
+	err = cfg.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+	// restarts append a new segment to the file.
+	for _, msg := range []string{"first", "second"} {
+		l, _, s, err := newLogger(&cfg)
+		if err != nil {
+			t.Fatal(err)
+		}
+		l.Info(msg, zap.String("card", "4111-1111"))
+		_ = s.close()
+	}
+
+	files, err := LogFiles(filepath.Join(dir, "app.log"))
+	if err != nil || len(files) != 1 {
+		t.Fatalf("unexpected files %v %v", files, err)
+	}
+	data, err := os.ReadFile(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	if bytes.Contains(data, []byte("4111")) || bytes.Count(data, []byte(encryptHeader)) != 2 {
+		t.Fatalf("file not encrypted\n%s", data)
+	}
+
+	r, err := NewReader(filepath.Join(dir, "app.log"), Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.PrivateKey = privateKey
+	var got []string
+	err = r.Read(func(ent Entry) bool {
+		got = append(got, ent.Message+" "+ent.Fields["card"].(string))
+		return true
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	if strings.Join(got, ",") != "first 4111-1111,second 4111-1111" {
+		t.Fatalf("got %q", got)
+	}
+
+	f, err := os.Open(files[0])
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	plain, err := io.ReadAll(NewDecryptReader(f, privateKey))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if lines := strings.Split(strings.TrimSpace(string(plain)), "\n"); len(lines) != 2 || !strings.Contains(lines[1], `"msg":"second"`) {
+		t.Fatalf("unexpected plain text\n%s", plain)
+	}
+
+	otherKey, _, err := cryptoutil.GenerateRSAKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.PrivateKey = otherKey
+	err = r.Read(func(Entry) bool { return true })
+	if err == nil || !strings.Contains(err.Error(), "unwrap data key") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	cfg.LogRotate = RotateSize
+	if err := cfg.Validate(); err == nil || !strings.Contains(err.Error(), "daily or hourly") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
+
+func TestEncryptedOutputSizeRotate(t *testing.T) {
+	_, publicKey, err := cryptoutil.GenerateRSAKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	// LogRotate is empty, the default size rotation.
+	cfg := Config{
+		LogLevel:    "info",
+		LogPath:     t.TempDir(),
+		LogFileName: "app.log",
+		Outputs:     []Output{{Type: OutputFile, Encrypt: &EncryptConfig{PublicKey: string(publicKey)}}},
+	}
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "outputs[0].encrypt") || !strings.Contains(err.Error(), "daily or hourly") {
+		t.Fatalf("unexpected error %v", err)
+	}
+	_, _, _, err = newLogger(&cfg)
+	if err == nil || !strings.Contains(err.Error(), "daily or hourly") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	cfg.Outputs[0].Rotate = RotateHourly
+	err = cfg.Validate()
+	if err != nil {
+		t.Fatal(err)
+	}
+}
+
+func TestEncryptedRotateAndFollow(t *testing.T) {
+	privateKey, publicKey, err := cryptoutil.GenerateRSAKey(2048)
+	if err != nil {
+		t.Fatal(err)
+	}
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	w := &TimeRotateWriter{
+		Filename:  filepath.Join(dir, "app.log"),
+		Compress:  true,
+		PublicKey: publicKey,
+		now:       func() time.Time { return now },
+	}
+	defer w.Close()
+	write := func(msg string) {
+		_, err := w.Write([]byte(`{"level":"INFO","msg":"` + msg + `"}` + "\n"))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("day1")
+	now = now.Add(24 * time.Hour)
+	write("day2")
+	w.millWg.Wait()
+
+	r, err := NewReader(filepath.Join(dir, "app.log"), Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.PrivateKey = privateKey
+	r.PollInterval = 10 * time.Millisecond
+
+	// the compressed backup is decrypted too.
+	var got []string
+	err = r.Read(func(ent Entry) bool {
+		got = append(got, ent.Message)
+		return true
+	})
+	if err != nil || strings.Join(got, ",") != "day1,day2" {
+		t.Fatalf("got %q %v", got, err)
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	messages := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Follow(ctx, func(ent Entry) bool {
+			messages <- ent.Message
+			return ent.Message != "day3"
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+	write("followed")
+	time.Sleep(50 * time.Millisecond)
+	now = now.Add(24 * time.Hour)
+	write("day3")
+
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(messages)
+	got = got[:0]
+	for msg := range messages {
+		got = append(got, msg)
+	}
+	if strings.Join(got, ",") != "followed,day3" {
+		t.Fatalf("got %q", got)
+	}
+}
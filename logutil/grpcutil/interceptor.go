@@ -0,0 +1,140 @@
+// Package grpcutil provides grpc access logging interceptors with the schema
+// of logutil.AccessLogMiddleware.
+package grpcutil
+
+import (
+	"context"
+	"time"
+
+	"github.com/booyangcc/utils/logutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/protobuf/proto"
+)
+
+// requestIDKey the metadata key of the request id, metadata keys are lower
+// case.
+const requestIDKey = "x-request-id"
+
+// Methods of the access entries.
+const (
+	MethodUnary  = "GRPC"
+	MethodStream = "GRPC_STREAM"
+)
+
+// UnaryServerInterceptor log every unary call after it is handled, the path
+// is the full method and the status is the grpc code. The handler context
+// carries the request id and a logger with request_id and path, see
+// logutil.FromContext.
+func UnaryServerInterceptor(l *zap.Logger, cfg logutil.AccessLogConfig) grpc.UnaryServerInterceptor {
+	a := logutil.NewAccessLogger(l, cfg)
+	return func(ctx context.Context, req interface{}, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		ctx, requestID := withRequest(ctx, l, info.FullMethod)
+		if a.Skip(info.FullMethod) {
+			return handler(ctx, req)
+		}
+
+		start := time.Now()
+		resp, err := handler(ctx, req)
+		var bytes int64
+		if msg, ok := resp.(proto.Message); ok && err == nil {
+			bytes = int64(proto.Size(msg))
+		}
+		a.Log(accessEntry(ctx, MethodUnary, info.FullMethod, requestID, bytes, time.Since(start), err))
+		return resp, err
+	}
+}
+
+// StreamServerInterceptor log every stream after it is closed, bytes is the
+// size of the messages sent.
+func StreamServerInterceptor(l *zap.Logger, cfg logutil.AccessLogConfig) grpc.StreamServerInterceptor {
+	a := logutil.NewAccessLogger(l, cfg)
+	return func(srv interface{}, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		ctx, requestID := withRequest(ss.Context(), l, info.FullMethod)
+		stream := &serverStream{ServerStream: ss, ctx: ctx}
+		if a.Skip(info.FullMethod) {
+			return handler(srv, stream)
+		}
+
+		start := time.Now()
+		err := handler(srv, stream)
+		a.Log(accessEntry(ctx, MethodStream, info.FullMethod, requestID, stream.bytes, time.Since(start), err))
+		return err
+	}
+}
+
+// withRequest returns ctx with the request id of the metadata, or a new one,
+// and a logger with request_id and path.
+func withRequest(ctx context.Context, l *zap.Logger, method string) (context.Context, string) {
+	var requestID string
+	if md, ok := metadata.FromIncomingContext(ctx); ok {
+		if ids := md.Get(requestIDKey); len(ids) > 0 {
+			requestID = ids[0]
+		}
+	}
+	if !logutil.ValidRequestID(requestID) {
+		requestID = logutil.NewRequestID()
+	}
+	ctx = logutil.WithRequestID(ctx, requestID)
+	ctx = logutil.WithContext(ctx, l)
+	ctx = logutil.WithFields(ctx, zap.String("request_id", requestID), zap.String("path", method))
+	return ctx, requestID
+}
+
+func accessEntry(ctx context.Context, method, fullMethod, requestID string, bytes int64, latency time.Duration, err error) logutil.AccessEntry {
+	code := status.Code(err)
+	ent := logutil.AccessEntry{
+		Level:     codeLevel(code),
+		Method:    method,
+		Path:      fullMethod,
+		Status:    int(code),
+		Bytes:     bytes,
+		Latency:   latency,
+		RequestID: requestID,
+		Fields:    []zap.Field{zap.String("grpc_code", code.String())},
+	}
+	if p, ok := peer.FromContext(ctx); ok && p.Addr != nil {
+		ent.RemoteAddr = p.Addr.String()
+	}
+	if err != nil {
+		ent.Fields = append(ent.Fields, zap.Error(err))
+	}
+	return ent
+}
+
+// codeLevel returns info for OK, warn for client errors and error for the
+// others.
+func codeLevel(code codes.Code) zapcore.Level {
+	switch code {
+	case codes.OK:
+		return zapcore.InfoLevel
+	case codes.Canceled, codes.InvalidArgument, codes.NotFound, codes.AlreadyExists,
+		codes.PermissionDenied, codes.Unauthenticated, codes.FailedPrecondition, codes.OutOfRange:
+		return zapcore.WarnLevel
+	}
+	return zapcore.ErrorLevel
+}
+
+// serverStream replace the context and count the bytes of the sent messages.
+type serverStream struct {
+	grpc.ServerStream
+	ctx   context.Context
+	bytes int64
+}
+
+func (s *serverStream) Context() context.Context {
+	return s.ctx
+}
+
+func (s *serverStream) SendMsg(m interface{}) error {
+	err := s.ServerStream.SendMsg(m)
+	if msg, ok := m.(proto.Message); ok && err == nil {
+		s.bytes += int64(proto.Size(msg))
+	}
+	return err
+}
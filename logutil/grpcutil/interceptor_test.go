@@ -0,0 +1,99 @@
+package grpcutil
+
+import (
+	"context"
+	"net"
+	"testing"
+	"time"
+
+	"github.com/booyangcc/utils/logutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials/insecure"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func TestInterceptors(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	l := zap.New(core)
+	cfg := logutil.AccessLogConfig{SkipPaths: []string{"/internal.*"}}
+
+	healthServer := health.NewServer()
+	healthServer.SetServingStatus("db", healthpb.HealthCheckResponse_SERVING)
+	srv := grpc.NewServer(
+		grpc.UnaryInterceptor(UnaryServerInterceptor(l, cfg)),
+		grpc.StreamInterceptor(StreamServerInterceptor(l, cfg)),
+	)
+	healthpb.RegisterHealthServer(srv, healthServer)
+	lis := bufconn.Listen(1 << 20)
+	go func() { _ = srv.Serve(lis) }()
+	defer srv.Stop()
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(insecure.NewCredentials()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+	client := healthpb.NewHealthClient(conn)
+
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+	ctx = metadata.AppendToOutgoingContext(ctx, "x-request-id", "req-1")
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = client.Check(ctx, &healthpb.HealthCheckRequest{Service: "missing"})
+	if status.Code(err) != codes.NotFound {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// skipped calls still get the request id.
+	skipped := UnaryServerInterceptor(l, cfg)
+	_, _ = skipped(ctx, nil, &grpc.UnaryServerInfo{FullMethod: "/internal.Admin/Ping"}, func(ctx context.Context, _ interface{}) (interface{}, error) {
+		if logutil.RequestIDFromContext(ctx) == "" {
+			t.Error("request id not set")
+		}
+		return nil, nil
+	})
+
+	stream, err := client.Watch(ctx, &healthpb.HealthCheckRequest{Service: "db"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = stream.Recv()
+	if err != nil {
+		t.Fatal(err)
+	}
+	cancel()
+	for i := 0; i < 100 && logs.Len() < 3; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	entries := logs.All()
+	if len(entries) != 3 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	fields := entries[0].ContextMap()
+	if entries[0].Level != zapcore.InfoLevel || fields["method"] != MethodUnary || fields["path"] != "/grpc.health.v1.Health/Check" ||
+		fields["status"] != int64(codes.OK) || fields["request_id"] != "req-1" || fields["bytes"] != int64(2) || fields["remote_addr"] == "" {
+		t.Errorf("unexpected unary entry %v", fields)
+	}
+	if entries[1].Level != zapcore.WarnLevel || entries[1].ContextMap()["grpc_code"] != "NotFound" {
+		t.Errorf("unexpected not found entry %s %v", entries[1].Level, entries[1].ContextMap())
+	}
+	fields = entries[2].ContextMap()
+	if fields["method"] != MethodStream || fields["path"] != "/grpc.health.v1.Health/Watch" || fields["bytes"] != int64(2) {
+		t.Errorf("unexpected stream entry %v", fields)
+	}
+}
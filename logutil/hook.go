@@ -0,0 +1,390 @@
+package logutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultHookQueueSize   = 256
+	defaultHookTimeout     = time.Second
+	defaultWebhookTimeout  = 5 * time.Second
+	hookDedupSweepInterval = time.Minute
+)
+
+// HookEntry the entry passed to hooks, fields include the fields added by
+// With.
+type HookEntry struct {
+	zapcore.Entry
+	Fields map[string]interface{}
+}
+
+// Hook called with the entries at or above the hook level. Hooks run in
+// background one entry at a time in the order of the entries, errors and
+// panics are counted and never reach the logger.
+type Hook func(ent HookEntry) error
+
+// WebhookConfig webhook hook config.
+type WebhookConfig struct {
+	URL     string            `json:"url" yaml:"url"`         // 接收地址, 以 json POST 日志
+	Headers map[string]string `json:"headers" yaml:"headers"` // 请求头
+	Timeout time.Duration     `json:"timeout" yaml:"timeout"` // 请求超时, 默认 5s
+}
+
+// HookConfig hook config.
+type HookConfig struct {
+	Level       string         `json:"level" yaml:"level"`               // 触发的最低级别, 默认 error
+	QueueSize   int            `json:"queue_size" yaml:"queue_size"`     // 等待执行的日志条数, 默认 256, 满时丢弃
+	DedupWindow time.Duration  `json:"dedup_window" yaml:"dedup_window"` // 同一级别和消息在窗口内只触发一次, 为 0 时不去重
+	Timeout     time.Duration  `json:"timeout" yaml:"timeout"`           // dpanic panic fatal 日志等待排队的日志和自身 hooks 的最长时间, 默认 1s
+	Webhook     *WebhookConfig `json:"webhook" yaml:"webhook"`           // webhook 配置, 为空时不发送
+	CrashFile   string         `json:"crash_file" yaml:"crash_file"`     // 崩溃文件路径, 写入 dpanic panic fatal 日志, 为空时不写入
+	Hooks       []Hook         `json:"-" yaml:"-"`                       // 自定义 hooks
+}
+
+// HookStats hook counters.
+type HookStats struct {
+	Fired   uint64 // 执行的日志条数
+	Deduped uint64 // 去重跳过的日志条数
+	Dropped uint64 // 队列满时丢弃的日志条数
+	Failed  uint64 // 返回错误, panic 或超时的 hook 次数
+}
+
+// hookItem a queued entry, done is closed after the hooks ran when not nil.
+type hookItem struct {
+	ent  HookEntry
+	done chan struct{}
+}
+
+// hookRunner run the hooks of the cores created by With in one goroutine.
+type hookRunner struct {
+	hooks   []Hook
+	window  time.Duration
+	timeout time.Duration
+
+	queue     chan hookItem
+	stopCh    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	mu        sync.Mutex
+	lastFired map[rateLimitKey]time.Time
+	lastSweep time.Time
+
+	fired   atomic.Uint64
+	deduped atomic.Uint64
+	dropped atomic.Uint64
+	failed  atomic.Uint64
+}
+
+type hookCore struct {
+	zapcore.LevelEnabler
+	fields []zapcore.Field
+	runner *hookRunner
+}
+
+// NewHookCore new core calling the hooks of cfg, the webhook and the crash
+// file with entries at or above cfg.Level. Entries up to error are queued
+// and dropped when the queue is full, for higher levels Write queues the
+// entry too and waits for the hooks of the queued entries and of it at most
+// cfg.Timeout since the process may exit after them.
+func NewHookCore(cfg HookConfig) (zapcore.Core, error) {
+	level := zapcore.ErrorLevel
+	if cfg.Level != "" {
+		var err error
+		level, err = parseLevel(cfg.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultHookQueueSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHookTimeout
+	}
+
+	hooks := append([]Hook(nil), cfg.Hooks...)
+	if cfg.Webhook != nil {
+		if cfg.Webhook.URL == "" {
+			return nil, errors.New("webhook without url")
+		}
+		hooks = append(hooks, WebhookHook(*cfg.Webhook))
+	}
+	if cfg.CrashFile != "" {
+		crash := CrashFileHook(cfg.CrashFile)
+		hooks = append(hooks, func(ent HookEntry) error {
+			if ent.Level <= zapcore.ErrorLevel {
+				return nil
+			}
+			return crash(ent)
+		})
+	}
+
+	r := &hookRunner{
+		hooks:     hooks,
+		window:    cfg.DedupWindow,
+		timeout:   cfg.Timeout,
+		queue:     make(chan hookItem, cfg.QueueSize),
+		stopCh:    make(chan struct{}),
+		done:      make(chan struct{}),
+		lastFired: make(map[rateLimitKey]time.Time),
+	}
+	go r.run()
+	return &hookCore{LevelEnabler: level, runner: r}, nil
+}
+
+func (c *hookCore) With(fields []zapcore.Field) zapcore.Core {
+	return &hookCore{
+		LevelEnabler: c.LevelEnabler,
+		fields:       append(c.fields[:len(c.fields):len(c.fields)], fields...),
+		runner:       c.runner,
+	}
+}
+
+func (c *hookCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *hookCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	if c.runner.duplicate(ent) {
+		c.runner.deduped.Add(1)
+		return nil
+	}
+
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range c.fields {
+		f.AddTo(enc)
+	}
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	hookEnt := HookEntry{Entry: ent, Fields: enc.Fields}
+
+	if ent.Level > zapcore.ErrorLevel {
+		c.runner.runWait(hookEnt)
+		return nil
+	}
+	select {
+	case c.runner.queue <- hookItem{ent: hookEnt}:
+	default:
+		c.runner.dropped.Add(1)
+	}
+	return nil
+}
+
+func (c *hookCore) Sync() error {
+	return nil
+}
+
+// Close run the queued entries and stop the hooks.
+func (c *hookCore) Close() error {
+	c.runner.closeOnce.Do(func() {
+		close(c.runner.stopCh)
+		<-c.runner.done
+	})
+	return nil
+}
+
+// Stats returns the counters of the hooks.
+func (c *hookCore) Stats() HookStats {
+	return HookStats{
+		Fired:   c.runner.fired.Load(),
+		Deduped: c.runner.deduped.Load(),
+		Dropped: c.runner.dropped.Load(),
+		Failed:  c.runner.failed.Load(),
+	}
+}
+
+func (r *hookRunner) run() {
+	defer close(r.done)
+	for {
+		select {
+		case item := <-r.queue:
+			r.fireItem(item)
+		case <-r.stopCh:
+			for {
+				select {
+				case item := <-r.queue:
+					r.fireItem(item)
+				default:
+					return
+				}
+			}
+		}
+	}
+}
+
+func (r *hookRunner) fireItem(item hookItem) {
+	r.fire(item.ent)
+	if item.done != nil {
+		close(item.done)
+	}
+}
+
+// runWait queue the entry after the queued ones and wait at most timeout for
+// its hooks, the hooks run in the calling goroutine after Close.
+func (r *hookRunner) runWait(ent HookEntry) {
+	timer := time.NewTimer(r.timeout)
+	defer timer.Stop()
+
+	item := hookItem{ent: ent, done: make(chan struct{})}
+	select {
+	case r.queue <- item:
+	case <-r.done:
+		r.fire(ent)
+		return
+	case <-timer.C:
+		r.failed.Add(1)
+		return
+	}
+	select {
+	case <-item.done:
+	case <-r.done:
+	case <-timer.C:
+		r.failed.Add(1)
+	}
+}
+
+func (r *hookRunner) fire(ent HookEntry) {
+	r.fired.Add(1)
+	for _, hook := range r.hooks {
+		if err := callHook(hook, ent); err != nil {
+			r.failed.Add(1)
+		}
+	}
+}
+
+func callHook(hook Hook, ent HookEntry) (err error) {
+	defer func() {
+		if v := recover(); v != nil {
+			err = fmt.Errorf("hook panic: %v", v)
+		}
+	}()
+	return hook(ent)
+}
+
+// duplicate reports whether an entry with the same level, logger and message
+// fired in the dedup window.
+func (r *hookRunner) duplicate(ent zapcore.Entry) bool {
+	if r.window <= 0 {
+		return false
+	}
+	key := rateLimitKey{level: ent.Level, logger: ent.LoggerName, message: ent.Message}
+	now := time.Now()
+
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if now.Sub(r.lastSweep) >= hookDedupSweepInterval {
+		for k, t := range r.lastFired {
+			if now.Sub(t) >= r.window {
+				delete(r.lastFired, k)
+			}
+		}
+		r.lastSweep = now
+	}
+	if last, ok := r.lastFired[key]; ok && now.Sub(last) < r.window {
+		return true
+	}
+	r.lastFired[key] = now
+	return false
+}
+
+// hookEntryJSON the json of the entry used by the built-in hooks.
+func hookEntryJSON(ent HookEntry) ([]byte, error) {
+	m := map[string]interface{}{
+		"time":    ent.Time.Format(time.RFC3339Nano),
+		"level":   ent.Level.String(),
+		"message": ent.Message,
+	}
+	if ent.LoggerName != "" {
+		m["logger"] = ent.LoggerName
+	}
+	if ent.Caller.Defined {
+		m["caller"] = ent.Caller.TrimmedPath()
+	}
+	if ent.Stack != "" {
+		m["stacktrace"] = ent.Stack
+	}
+	if len(ent.Fields) > 0 {
+		m["fields"] = ent.Fields
+	}
+	return json.Marshal(m)
+}
+
+// WebhookHook returns a hook posting the entry as json to cfg.URL.
+func WebhookHook(cfg WebhookConfig) Hook {
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultWebhookTimeout
+	}
+	client := &http.Client{Timeout: cfg.Timeout}
+	return func(ent HookEntry) error {
+		body, err := hookEntryJSON(ent)
+		if err != nil {
+			return err
+		}
+		req, err := http.NewRequest(http.MethodPost, cfg.URL, bytes.NewReader(body))
+		if err != nil {
+			return err
+		}
+		req.Header.Set("Content-Type", "application/json")
+		for key, value := range cfg.Headers {
+			req.Header.Set(key, value)
+		}
+		resp, err := client.Do(req)
+		if err != nil {
+			return err
+		}
+		_ = resp.Body.Close()
+		if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+			return fmt.Errorf("webhook status %d", resp.StatusCode)
+		}
+		return nil
+	}
+}
+
+// CrashFileHook returns a hook appending the entry as a json line to path.
+func CrashFileHook(path string) Hook {
+	var mu sync.Mutex
+	return func(ent HookEntry) error {
+		data, err := hookEntryJSON(ent)
+		if err != nil {
+			return err
+		}
+
+		mu.Lock()
+		defer mu.Unlock()
+		err = os.MkdirAll(filepath.Dir(path), 0o755)
+		if err != nil {
+			return err
+		}
+		f, err := os.OpenFile(path, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+		if err != nil {
+			return err
+		}
+		_, err = f.Write(append(data, '\n'))
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		err = f.Sync()
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+		return f.Close()
+	}
+}
@@ -0,0 +1,183 @@
+package logutil
+
+import (
+	"encoding/json"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestHookCore(t *testing.T) {
+	var (
+		mu       sync.Mutex
+		received []HookEntry
+	)
+	webhook := make(chan map[string]interface{}, 10)
+	srv := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		data, _ := io.ReadAll(r.Body)
+		var m map[string]interface{}
+		_ = json.Unmarshal(data, &m)
+		webhook <- m
+	}))
+	defer srv.Close()
+
+	crash := filepath.Join(t.TempDir(), "crash", "crash.log")
+	l, err := New(&Config{
+		LogLevel:  "debug",
+		LogFormat: "json",
+		LogType:   LogStdout,
+		Redact:    &RedactConfig{Fields: []string{"password"}},
+	}, WithHook(HookConfig{
+		DedupWindow: time.Hour,
+		Webhook:     &WebhookConfig{URL: srv.URL, Headers: map[string]string{"X-Token": "t"}},
+		CrashFile:   crash,
+	}), WithHooks(func(ent HookEntry) error {
+		mu.Lock()
+		defer mu.Unlock()
+		received = append(received, ent)
+		return nil
+	}, func(HookEntry) error {
+		panic("broken hook")
+	}, func(HookEntry) error {
+		return errors.New("failed hook")
+	}))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l = l.Named("db").With(zap.String("password", "secret"))
+	l.Info("ignored")
+	l.Error("query failed", zap.Int("attempt", 1))
+	l.Error("query failed", zap.Int("attempt", 2))
+	l.DPanic("corrupted")
+
+	// dpanic hooks run in the caller while error hooks run in background, so
+	// the order is not fixed.
+	for i := 0; i < 2; i++ {
+		select {
+		case m := <-webhook:
+			if m["logger"] != "db" {
+				t.Fatalf("unexpected webhook %v", m)
+			}
+			fields := m["fields"].(map[string]interface{})
+			if fields["password"] == "secret" {
+				t.Fatalf("webhook fields not redacted %v", fields)
+			}
+			if m["message"] == "query failed" && fields["attempt"] != float64(1) {
+				t.Fatalf("duplicate entry not skipped %v", fields)
+			}
+		case <-time.After(5 * time.Second):
+			t.Fatal("webhook not called")
+		}
+	}
+
+	err = lastSinks.Load().close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	mu.Lock()
+	messages := make([]string, 0, len(received))
+	for _, ent := range received {
+		messages = append(messages, ent.Message)
+	}
+	sort.Strings(messages)
+	if strings.Join(messages, ",") != "corrupted,query failed" {
+		t.Fatalf("unexpected hook entries %v", received)
+	}
+	mu.Unlock()
+
+	data, err := os.ReadFile(crash)
+	if err != nil {
+		t.Fatal(err)
+	}
+	lines := strings.Split(strings.TrimSpace(string(data)), "\n")
+	if len(lines) != 1 || !strings.Contains(lines[0], `"message":"corrupted"`) {
+		t.Fatalf("unexpected crash file %s", data)
+	}
+}
+
+func TestHookCoreNeverBlocks(t *testing.T) {
+	started := make(chan struct{}, 10)
+	release := make(chan struct{})
+	core, err := NewHookCore(HookConfig{
+		QueueSize: 1,
+		Timeout:   50 * time.Millisecond,
+		Hooks: []Hook{func(HookEntry) error {
+			started <- struct{}{}
+			<-release
+			return nil
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(core)
+
+	l.Error("blocked")
+	<-started
+	start := time.Now()
+	for i := 0; i < 9; i++ {
+		l.Error("blocked")
+	}
+	l.DPanic("waits at most timeout")
+	if took := time.Since(start); took > time.Second {
+		t.Fatalf("logging blocked for %s", took)
+	}
+	close(release)
+
+	hc := core.(*hookCore)
+	_ = hc.Close()
+	stats := hc.Stats()
+	// the first entry is running, the second is queued, the dpanic entry
+	// waits behind them and times out.
+	if stats.Dropped != 8 || stats.Failed != 1 || stats.Fired != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestHookCoreOrder(t *testing.T) {
+	var mu sync.Mutex
+	var msgs []string
+	var running, maxRunning atomic.Int32
+	core, err := NewHookCore(HookConfig{
+		QueueSize: 10,
+		Hooks: []Hook{func(ent HookEntry) error {
+			if n := running.Add(1); n > maxRunning.Load() {
+				maxRunning.Store(n)
+			}
+			defer running.Add(-1)
+			time.Sleep(5 * time.Millisecond)
+			mu.Lock()
+			msgs = append(msgs, ent.Message)
+			mu.Unlock()
+			return nil
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(core)
+	l.Error("first")
+	l.Error("second")
+	// dpanic waits for the hooks after the queued entries.
+	l.DPanic("third")
+
+	mu.Lock()
+	got := strings.Join(msgs, ",")
+	mu.Unlock()
+	if got != "first,second,third" || maxRunning.Load() != 1 {
+		t.Fatalf("got %q with %d hooks running at once", got, maxRunning.Load())
+	}
+	_ = core.(*hookCore).Close()
+}
@@ -0,0 +1,750 @@
+package logutil
+
+import (
+	"bytes"
+	"compress/gzip"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// http formats of HTTPConfig.
+const (
+	HTTPFormatJSONLines     = "jsonl"
+	HTTPFormatLoki          = "loki"
+	HTTPFormatElasticsearch = "elasticsearch"
+)
+
+const (
+	defaultHTTPBatchSize      = 1000
+	defaultHTTPBatchBytes     = 1 << 20
+	defaultHTTPFlushInterval  = time.Second
+	defaultHTTPQueueSize      = 8
+	defaultHTTPTimeout        = 10 * time.Second
+	defaultHTTPSyncTimeout    = 5 * time.Second
+	defaultHTTPMaxRetries     = 3
+	defaultHTTPRetryBackoff   = 500 * time.Millisecond
+	maxHTTPRetryBackoff       = 30 * time.Second
+	defaultHTTPSpoolMaxSize   = 100
+	defaultElasticsearchIndex = "logs"
+	spoolExt                  = ".spool"
+)
+
+// HTTPConfig http output config.
+type HTTPConfig struct {
+	URL           string            `json:"url" yaml:"url"`                       // 接收地址, 如 http://loki:3100/loki/api/v1/push
+	Format        string            `json:"format" yaml:"format"`                 // 请求格式 jsonl loki elasticsearch, 默认 jsonl
+	Headers       map[string]string `json:"headers" yaml:"headers"`               // 请求头, 如 Authorization
+	Labels        map[string]string `json:"labels" yaml:"labels"`                 // loki stream 标签, 另加 level 标签
+	Index         string            `json:"index" yaml:"index"`                   // elasticsearch 索引, 默认 logs
+	BatchSize     int               `json:"batch_size" yaml:"batch_size"`         // 每批最多条数, 默认 1000
+	BatchBytes    int               `json:"batch_bytes" yaml:"batch_bytes"`       // 每批最多字节数, 默认 1MB
+	FlushInterval time.Duration     `json:"flush_interval" yaml:"flush_interval"` // 发送间隔, 默认 1s
+	QueueSize     int               `json:"queue_size" yaml:"queue_size"`         // 内存中等待发送的批次数, 默认 8, 满时写入磁盘缓冲或丢弃
+	Gzip          bool              `json:"gzip" yaml:"gzip"`                     // 是否 gzip 压缩请求
+	Timeout       time.Duration     `json:"timeout" yaml:"timeout"`               // 请求超时, 默认 10s
+	SyncTimeout   time.Duration     `json:"sync_timeout" yaml:"sync_timeout"`     // Sync 等待发送的最长时间, 默认 5s
+	MaxRetries    int               `json:"max_retries" yaml:"max_retries"`       // 失败重试次数, 默认 3, 小于 0 时不重试
+	RetryBackoff  time.Duration     `json:"retry_backoff" yaml:"retry_backoff"`   // 首次重试间隔, 之后翻倍, 最多 30s, 默认 500ms
+	SpoolDir      string            `json:"spool_dir" yaml:"spool_dir"`           // 磁盘缓冲目录, 发送失败的批次写入该目录, 恢复后重发, 为空时丢弃
+	SpoolMaxSize  int               `json:"spool_max_size" yaml:"spool_max_size"` // 磁盘缓冲最大容量 单位(mb), 默认 100, 超出时删除最旧的批次
+	Client        *http.Client      `json:"-" yaml:"-"`                           // http client, 为空时使用 Timeout 创建
+}
+
+// HTTPStats http shipper counters.
+type HTTPStats struct {
+	Entries    uint64 // 收到的日志条数
+	Sent       uint64 // 发送成功的日志条数
+	Batches    uint64 // 发送成功的批次数
+	Retries    uint64 // 重试次数
+	Failed     uint64 // 发送失败的批次数
+	Dropped    uint64 // 丢弃的日志条数
+	Spooled    uint64 // 写入磁盘缓冲的批次数
+	Queued     int    // 内存中等待发送的批次数
+	SpoolBytes int64  // 磁盘缓冲的字节数
+}
+
+type httpRecord struct {
+	time  time.Time
+	level zapcore.Level
+	line  []byte
+}
+
+type httpBatch struct {
+	records []httpRecord
+	bytes   int
+}
+
+// HTTPShipper ship log lines to an http collector in batches. A batch is
+// sent when it is full or every FlushInterval, failed batches are retried
+// with backoff and then written to SpoolDir, they are sent again when the
+// collector is back. Logging never blocks on the collector or the disk: when
+// the queue is full batches are handed to the sender to be written to
+// SpoolDir, or are dropped without it or when the sender is behind too.
+type HTTPShipper struct {
+	cfg    HTTPConfig
+	client *http.Client
+
+	mu     sync.Mutex
+	batch  httpBatch
+	closed bool
+
+	queue     chan httpBatch
+	spoolCh   chan httpBatch // overflow of queue, written to SpoolDir by the sender
+	flushCh   chan chan error
+	stopCh    chan struct{}
+	done      chan struct{}
+	closeOnce sync.Once
+
+	spoolMu    sync.Mutex
+	spoolSeq   atomic.Uint64
+	spoolBytes atomic.Int64
+
+	entries atomic.Uint64
+	sent    atomic.Uint64
+	batches atomic.Uint64
+	retries atomic.Uint64
+	failed  atomic.Uint64
+	dropped atomic.Uint64
+	spooled atomic.Uint64
+}
+
+// NewHTTPShipper new http shipper, batches left in SpoolDir by the last run
+// are sent again.
+func NewHTTPShipper(cfg HTTPConfig) (*HTTPShipper, error) {
+	if cfg.URL == "" {
+		return nil, errors.New("http output without url")
+	}
+	switch cfg.Format {
+	case "":
+		cfg.Format = HTTPFormatJSONLines
+	case HTTPFormatJSONLines, HTTPFormatLoki, HTTPFormatElasticsearch:
+	default:
+		return nil, fmt.Errorf("unknown http format %q", cfg.Format)
+	}
+	if cfg.Index == "" {
+		cfg.Index = defaultElasticsearchIndex
+	}
+	if cfg.BatchSize <= 0 {
+		cfg.BatchSize = defaultHTTPBatchSize
+	}
+	if cfg.BatchBytes <= 0 {
+		cfg.BatchBytes = defaultHTTPBatchBytes
+	}
+	if cfg.FlushInterval <= 0 {
+		cfg.FlushInterval = defaultHTTPFlushInterval
+	}
+	if cfg.QueueSize <= 0 {
+		cfg.QueueSize = defaultHTTPQueueSize
+	}
+	if cfg.Timeout <= 0 {
+		cfg.Timeout = defaultHTTPTimeout
+	}
+	if cfg.SyncTimeout <= 0 {
+		cfg.SyncTimeout = defaultHTTPSyncTimeout
+	}
+	if cfg.MaxRetries == 0 {
+		cfg.MaxRetries = defaultHTTPMaxRetries
+	}
+	if cfg.RetryBackoff <= 0 {
+		cfg.RetryBackoff = defaultHTTPRetryBackoff
+	}
+	if cfg.SpoolMaxSize <= 0 {
+		cfg.SpoolMaxSize = defaultHTTPSpoolMaxSize
+	}
+
+	s := &HTTPShipper{
+		cfg:     cfg,
+		client:  cfg.Client,
+		queue:   make(chan httpBatch, cfg.QueueSize),
+		spoolCh: make(chan httpBatch, cfg.QueueSize),
+		flushCh: make(chan chan error),
+		stopCh:  make(chan struct{}),
+		done:    make(chan struct{}),
+	}
+	if s.client == nil {
+		s.client = &http.Client{Timeout: cfg.Timeout}
+	}
+	if cfg.SpoolDir != "" {
+		err := os.MkdirAll(cfg.SpoolDir, 0o755)
+		if err != nil {
+			return nil, err
+		}
+		files, err := s.spoolFiles()
+		if err != nil {
+			return nil, err
+		}
+		for _, f := range files {
+			s.spoolBytes.Add(f.size)
+		}
+	}
+	go s.run()
+	return s, nil
+}
+
+// Stats returns the counters of the shipper.
+func (s *HTTPShipper) Stats() HTTPStats {
+	return HTTPStats{
+		Entries:    s.entries.Load(),
+		Sent:       s.sent.Load(),
+		Batches:    s.batches.Load(),
+		Retries:    s.retries.Load(),
+		Failed:     s.failed.Load(),
+		Dropped:    s.dropped.Load(),
+		Spooled:    s.spooled.Load(),
+		Queued:     len(s.queue),
+		SpoolBytes: s.spoolBytes.Load(),
+	}
+}
+
+// Sync send the buffered batches and wait for them at most SyncTimeout, it
+// returns the last send error of them. The batches still being sent after
+// the timeout are retried and spooled as usual.
+func (s *HTTPShipper) Sync() error {
+	timer := time.NewTimer(s.cfg.SyncTimeout)
+	defer timer.Stop()
+	ack := make(chan error, 1)
+	select {
+	case s.flushCh <- ack:
+	case <-s.done:
+		return nil
+	case <-timer.C:
+		return fmt.Errorf("http sync timed out after %s", s.cfg.SyncTimeout)
+	}
+	select {
+	case err := <-ack:
+		return err
+	case <-timer.C:
+		return fmt.Errorf("http sync timed out after %s", s.cfg.SyncTimeout)
+	}
+}
+
+// Close send the buffered batches and stop the shipper, lines added after
+// Close are dropped.
+func (s *HTTPShipper) Close() error {
+	s.closeOnce.Do(func() {
+		s.mu.Lock()
+		s.closed = true
+		s.mu.Unlock()
+		close(s.stopCh)
+		<-s.done
+	})
+	return nil
+}
+
+// add buffer the line, the batch is queued when it is full.
+func (s *HTTPShipper) add(rec httpRecord) {
+	s.entries.Add(1)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if s.closed {
+		s.dropped.Add(1)
+		return
+	}
+	s.batch.records = append(s.batch.records, rec)
+	s.batch.bytes += len(rec.line)
+	if len(s.batch.records) < s.cfg.BatchSize && s.batch.bytes < s.cfg.BatchBytes {
+		return
+	}
+
+	batch := s.batch
+	s.batch = httpBatch{}
+	select {
+	case s.queue <- batch:
+		return
+	default:
+	}
+	// backpressure, the sender is behind, it spools the batch.
+	if s.cfg.SpoolDir != "" {
+		select {
+		case s.spoolCh <- batch:
+			return
+		default:
+		}
+	}
+	s.dropped.Add(uint64(len(batch.records)))
+}
+
+// take returns the current batch.
+func (s *HTTPShipper) take() httpBatch {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	batch := s.batch
+	s.batch = httpBatch{}
+	return batch
+}
+
+func (s *HTTPShipper) run() {
+	defer close(s.done)
+
+	ticker := time.NewTicker(s.cfg.FlushInterval)
+	defer ticker.Stop()
+	s.resendSpool()
+	for {
+		select {
+		case <-s.stopCh:
+			s.drain()
+			s.ship(s.take())
+			return
+		case batch := <-s.queue:
+			s.ship(batch)
+		case batch := <-s.spoolCh:
+			s.spool(s.encode(batch.records), len(batch.records))
+		case <-ticker.C:
+			s.resendSpool()
+			s.ship(s.take())
+		case ack := <-s.flushCh:
+			// spool the overflow first so it is resent before the queue.
+			s.spoolOverflow()
+			err := s.resendSpool()
+			if drainErr := s.drain(); drainErr != nil {
+				err = drainErr
+			}
+			if shipErr := s.ship(s.take()); shipErr != nil {
+				err = shipErr
+			}
+			ack <- err
+		}
+	}
+}
+
+// drain ship the queued batches, it returns the last send error.
+func (s *HTTPShipper) drain() error {
+	s.spoolOverflow()
+	var lastErr error
+	for {
+		select {
+		case batch := <-s.queue:
+			if err := s.ship(batch); err != nil {
+				lastErr = err
+			}
+		default:
+			return lastErr
+		}
+	}
+}
+
+// spoolOverflow write the batches handed over by add to SpoolDir.
+func (s *HTTPShipper) spoolOverflow() {
+	for {
+		select {
+		case batch := <-s.spoolCh:
+			s.spool(s.encode(batch.records), len(batch.records))
+		default:
+			return
+		}
+	}
+}
+
+// ship send the batch, the entries not sent are spooled.
+func (s *HTTPShipper) ship(batch httpBatch) error {
+	if len(batch.records) == 0 {
+		return nil
+	}
+	res := s.post(s.encode(batch.records), len(batch.records), s.cfg.MaxRetries)
+	s.sent.Add(uint64(res.sent))
+	if res.err != nil {
+		s.failed.Add(1)
+		if res.entries > 0 {
+			s.spool(res.rest, res.entries)
+		}
+		return res.err
+	}
+	s.batches.Add(1)
+	return nil
+}
+
+// postResult the result of post.
+type postResult struct {
+	sent    int    // entries sent
+	rest    []byte // body of the entries not sent and to be retried later
+	entries int    // entries in rest
+	err     error  // the last send error
+}
+
+// post send body of entries, it retries network errors, 429 and 5xx
+// responses at most maxRetries times. Only the failed items of elasticsearch
+// bulk responses are retried, the items rejected by other statuses are
+// dropped.
+func (s *HTTPShipper) post(body []byte, entries int, maxRetries int) postResult {
+	res := postResult{}
+	backoff := s.cfg.RetryBackoff
+	for attempt := 0; ; attempt++ {
+		err := s.send(body)
+		if err == nil {
+			res.sent += entries
+			return res
+		}
+		var bulkErr *bulkError
+		if errors.As(err, &bulkErr) {
+			res.sent += entries - bulkErr.failed
+			s.dropped.Add(uint64(bulkErr.failed - bulkErr.retryItems))
+			body, entries = bulkErr.retry, bulkErr.retryItems
+		}
+		var statusErr *httpStatusError
+		if entries == 0 || attempt >= maxRetries || (errors.As(err, &statusErr) && !statusErr.retryable()) {
+			res.rest, res.entries, res.err = body, entries, err
+			return res
+		}
+
+		s.retries.Add(1)
+		timer := time.NewTimer(backoff)
+		for waiting := true; waiting; {
+			select {
+			case <-timer.C:
+				waiting = false
+			case batch := <-s.spoolCh:
+				// keep spooling the overflow while waiting.
+				s.spool(s.encode(batch.records), len(batch.records))
+			case <-s.stopCh:
+				timer.Stop()
+				res.rest, res.entries, res.err = body, entries, err
+				return res
+			}
+		}
+		backoff *= 2
+		if backoff > maxHTTPRetryBackoff {
+			backoff = maxHTTPRetryBackoff
+		}
+	}
+}
+
+type httpStatusError struct {
+	code int
+	body string
+}
+
+func (e *httpStatusError) Error() string {
+	return fmt.Sprintf("http status %d: %s", e.code, e.body)
+}
+
+func (e *httpStatusError) retryable() bool {
+	return retryableStatus(e.code)
+}
+
+func retryableStatus(code int) bool {
+	return code == http.StatusTooManyRequests || code >= 500
+}
+
+// bulkError the items failed in an elasticsearch bulk response.
+type bulkError struct {
+	failed     int    // failed items
+	retry      []byte // body of the failed items with 429 and 5xx statuses
+	retryItems int    // items in retry
+	reason     string // the error of the first failed item
+}
+
+func (e *bulkError) Error() string {
+	return fmt.Sprintf("elasticsearch bulk: %d items failed: %s", e.failed, e.reason)
+}
+
+type bulkResponse struct {
+	Errors bool `json:"errors"`
+	Items  []map[string]struct {
+		Status int             `json:"status"`
+		Error  json.RawMessage `json:"error"`
+	} `json:"items"`
+}
+
+// checkBulk returns a bulkError when items of the bulk response failed, the
+// action and document lines of the items are in body.
+func checkBulk(body, data []byte) error {
+	var resp bulkResponse
+	if json.Unmarshal(data, &resp) != nil || !resp.Errors {
+		return nil
+	}
+	lines := bytes.SplitAfter(body, []byte("\n"))
+	e := &bulkError{}
+	for i, item := range resp.Items {
+		for _, result := range item {
+			if result.Status >= 200 && result.Status < 300 {
+				continue
+			}
+			e.failed++
+			if e.reason == "" {
+				e.reason = fmt.Sprintf("status %d %s", result.Status, result.Error)
+			}
+			if retryableStatus(result.Status) && 2*i+1 < len(lines) {
+				e.retry = append(e.retry, lines[2*i]...)
+				e.retry = append(e.retry, lines[2*i+1]...)
+				e.retryItems++
+			}
+		}
+	}
+	if e.failed == 0 {
+		return nil
+	}
+	return e
+}
+
+func (s *HTTPShipper) send(body []byte) error {
+	var reader io.Reader = bytes.NewReader(body)
+	if s.cfg.Gzip {
+		var buf bytes.Buffer
+		zw := gzip.NewWriter(&buf)
+		_, _ = zw.Write(body)
+		_ = zw.Close()
+		reader = &buf
+	}
+
+	req, err := http.NewRequest(http.MethodPost, s.cfg.URL, reader)
+	if err != nil {
+		return err
+	}
+	if s.cfg.Format == HTTPFormatLoki {
+		req.Header.Set("Content-Type", "application/json")
+	} else {
+		req.Header.Set("Content-Type", "application/x-ndjson")
+	}
+	if s.cfg.Gzip {
+		req.Header.Set("Content-Encoding", "gzip")
+	}
+	for key, value := range s.cfg.Headers {
+		req.Header.Set(key, value)
+	}
+
+	resp, err := s.client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode < 200 || resp.StatusCode >= 300 {
+		data, _ := io.ReadAll(io.LimitReader(resp.Body, 1024))
+		return &httpStatusError{code: resp.StatusCode, body: strings.TrimSpace(string(data))}
+	}
+	// a bulk request succeeds with failed items.
+	if s.cfg.Format == HTTPFormatElasticsearch {
+		data, err := io.ReadAll(resp.Body)
+		if err != nil {
+			return err
+		}
+		return checkBulk(body, data)
+	}
+	return nil
+}
+
+type lokiPush struct {
+	Streams []lokiStream `json:"streams"`
+}
+
+type lokiStream struct {
+	Stream map[string]string `json:"stream"`
+	Values [][2]string       `json:"values"`
+}
+
+// encode returns the request body of the records.
+func (s *HTTPShipper) encode(records []httpRecord) []byte {
+	var buf bytes.Buffer
+	switch s.cfg.Format {
+	case HTTPFormatLoki:
+		// one stream per level.
+		push := lokiPush{}
+		streams := make(map[zapcore.Level]int)
+		for _, rec := range records {
+			i, ok := streams[rec.level]
+			if !ok {
+				labels := make(map[string]string, len(s.cfg.Labels)+1)
+				for key, value := range s.cfg.Labels {
+					labels[key] = value
+				}
+				labels["level"] = rec.level.String()
+				i = len(push.Streams)
+				streams[rec.level] = i
+				push.Streams = append(push.Streams, lokiStream{Stream: labels})
+			}
+			value := [2]string{strconv.FormatInt(rec.time.UnixNano(), 10), string(rec.line)}
+			push.Streams[i].Values = append(push.Streams[i].Values, value)
+		}
+		_ = json.NewEncoder(&buf).Encode(push)
+	case HTTPFormatElasticsearch:
+		action, _ := json.Marshal(map[string]map[string]string{"index": {"_index": s.cfg.Index}})
+		for _, rec := range records {
+			buf.Write(action)
+			buf.WriteByte('\n')
+			buf.Write(rec.line)
+			buf.WriteByte('\n')
+		}
+	default:
+		for _, rec := range records {
+			buf.Write(rec.line)
+			buf.WriteByte('\n')
+		}
+	}
+	return buf.Bytes()
+}
+
+type spoolFile struct {
+	path    string
+	size    int64
+	entries int
+}
+
+// spool write the body to SpoolDir as <unix nano>-<seq>-<entries>.spool, the
+// oldest files are removed when SpoolMaxSize is exceeded. Without SpoolDir
+// the entries are dropped.
+func (s *HTTPShipper) spool(body []byte, entries int) {
+	if s.cfg.SpoolDir == "" {
+		s.dropped.Add(uint64(entries))
+		return
+	}
+
+	s.spoolMu.Lock()
+	defer s.spoolMu.Unlock()
+	name := fmt.Sprintf("%020d-%06d-%d%s", time.Now().UnixNano(), s.spoolSeq.Add(1)%1000000, entries, spoolExt)
+	path := filepath.Join(s.cfg.SpoolDir, name)
+	err := os.WriteFile(path+".tmp", body, 0o644)
+	if err == nil {
+		err = os.Rename(path+".tmp", path)
+	}
+	if err != nil {
+		_ = os.Remove(path + ".tmp")
+		s.dropped.Add(uint64(entries))
+		return
+	}
+	s.spooled.Add(1)
+	total := s.spoolBytes.Add(int64(len(body)))
+
+	maxSize := int64(s.cfg.SpoolMaxSize) * megabyte
+	if total <= maxSize {
+		return
+	}
+	files, _ := s.spoolFiles()
+	for _, f := range files {
+		if total <= maxSize {
+			break
+		}
+		if os.Remove(f.path) == nil {
+			total = s.spoolBytes.Add(-f.size)
+			s.dropped.Add(uint64(f.entries))
+		}
+	}
+}
+
+// resendSpool send the spooled batches oldest first without retries, it
+// stops at the first failure and returns its error. The entries of a batch
+// not sent are spooled again.
+func (s *HTTPShipper) resendSpool() error {
+	if s.cfg.SpoolDir == "" || s.spoolBytes.Load() == 0 {
+		return nil
+	}
+
+	s.spoolMu.Lock()
+	files, err := s.spoolFiles()
+	s.spoolMu.Unlock()
+	if err != nil {
+		return err
+	}
+	for _, f := range files {
+		body, err := os.ReadFile(f.path)
+		if err != nil {
+			continue
+		}
+		res := s.post(body, f.entries, 0)
+		s.sent.Add(uint64(res.sent))
+		if res.err != nil && res.sent == 0 && res.entries == f.entries {
+			return res.err
+		}
+
+		s.spoolMu.Lock()
+		if os.Remove(f.path) == nil {
+			s.spoolBytes.Add(-f.size)
+		}
+		s.spoolMu.Unlock()
+		if res.err != nil {
+			if res.entries > 0 {
+				s.spool(res.rest, res.entries)
+			}
+			return res.err
+		}
+		s.batches.Add(1)
+	}
+	return nil
+}
+
+// spoolFiles returns the spool files sorted oldest first.
+func (s *HTTPShipper) spoolFiles() ([]spoolFile, error) {
+	entries, err := os.ReadDir(s.cfg.SpoolDir)
+	if err != nil {
+		return nil, err
+	}
+	var files []spoolFile
+	for _, entry := range entries {
+		name := entry.Name()
+		if entry.IsDir() || !strings.HasSuffix(name, spoolExt) {
+			continue
+		}
+		info, err := entry.Info()
+		if err != nil {
+			continue
+		}
+		parts := strings.Split(strings.TrimSuffix(name, spoolExt), "-")
+		n, _ := strconv.Atoi(parts[len(parts)-1])
+		files = append(files, spoolFile{path: filepath.Join(s.cfg.SpoolDir, name), size: info.Size(), entries: n})
+	}
+	sort.Slice(files, func(i, j int) bool { return files[i].path < files[j].path })
+	return files, nil
+}
+
+// httpCore encode entries and add them to the shipper.
+type httpCore struct {
+	zapcore.LevelEnabler
+	enc     zapcore.Encoder
+	shipper *HTTPShipper
+}
+
+// NewHTTPCore new core shipping entries encoded by enc with the shipper, the
+// encoder must write json for the jsonl and elasticsearch formats.
+func NewHTTPCore(shipper *HTTPShipper, enc zapcore.Encoder, enab zapcore.LevelEnabler) zapcore.Core {
+	return &httpCore{LevelEnabler: enab, enc: enc, shipper: shipper}
+}
+
+func (c *httpCore) With(fields []zapcore.Field) zapcore.Core {
+	enc := c.enc.Clone()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	return &httpCore{LevelEnabler: c.LevelEnabler, enc: enc, shipper: c.shipper}
+}
+
+func (c *httpCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *httpCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	buf, err := c.enc.EncodeEntry(ent, fields)
+	if err != nil {
+		return err
+	}
+	line := append([]byte(nil), bytes.TrimRight(buf.Bytes(), "\n")...)
+	buf.Free()
+
+	c.shipper.add(httpRecord{time: ent.Time, level: ent.Level, line: line})
+	if ent.Level > zapcore.ErrorLevel {
+		return c.shipper.Sync()
+	}
+	return nil
+}
+
+func (c *httpCore) Sync() error {
+	return c.shipper.Sync()
+}
+
+// Close send the buffered entries and stop the shipper.
+func (c *httpCore) Close() error {
+	return c.shipper.Close()
+}
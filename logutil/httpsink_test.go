@@ -0,0 +1,285 @@
+package logutil
+
+import (
+	"compress/gzip"
+	"encoding/json"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+type collector struct {
+	mu     sync.Mutex
+	bodies []string
+	down   atomic.Bool
+}
+
+func (c *collector) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if c.down.Load() {
+		w.WriteHeader(http.StatusServiceUnavailable)
+		return
+	}
+	var reader io.Reader = r.Body
+	if r.Header.Get("Content-Encoding") == "gzip" {
+		zr, err := gzip.NewReader(r.Body)
+		if err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		reader = zr
+	}
+	data, _ := io.ReadAll(reader)
+	c.mu.Lock()
+	c.bodies = append(c.bodies, string(data))
+	c.mu.Unlock()
+}
+
+func (c *collector) received() []string {
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	return append([]string(nil), c.bodies...)
+}
+
+func TestHTTPLoki(t *testing.T) {
+	c := &collector{}
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	l, err := New(&Config{
+		LogLevel: "debug",
+		Outputs: []Output{{
+			Type:   OutputHTTP,
+			Format: "logfmt",
+			HTTP: &HTTPConfig{
+				URL:           server.URL,
+				Format:        HTTPFormatLoki,
+				Labels:        map[string]string{"app": "test"},
+				BatchSize:     3,
+				FlushInterval: time.Hour,
+				Gzip:          true,
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("one")
+	l.Warn("two")
+	l.Info("three")
+	_ = l.Sync()
+
+	bodies := c.received()
+	if len(bodies) != 1 {
+		t.Fatalf("expected one batch, got %q", bodies)
+	}
+	var push lokiPush
+	err = json.Unmarshal([]byte(bodies[0]), &push)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if len(push.Streams) != 2 || push.Streams[0].Stream["level"] != "info" || push.Streams[0].Stream["app"] != "test" {
+		t.Fatalf("unexpected streams %+v", push.Streams)
+	}
+	if len(push.Streams[0].Values) != 2 || !strings.Contains(push.Streams[0].Values[1][1], "msg=three") {
+		t.Fatalf("unexpected values %+v", push.Streams[0].Values)
+	}
+}
+
+func TestHTTPSpool(t *testing.T) {
+	c := &collector{}
+	c.down.Store(true)
+	server := httptest.NewServer(c)
+	defer server.Close()
+
+	shipper, err := NewHTTPShipper(HTTPConfig{
+		URL:           server.URL,
+		Format:        HTTPFormatElasticsearch,
+		Index:         "app",
+		FlushInterval: time.Hour,
+		MaxRetries:    1,
+		RetryBackoff:  time.Millisecond,
+		SpoolDir:      t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shipper.Close()
+	enc, err := getEncoder("json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(NewHTTPCore(shipper, enc, zap.InfoLevel))
+
+	l.Info("lost", zap.Int("n", 1))
+	_ = l.Sync()
+	stats := shipper.Stats()
+	if stats.Retries != 1 || stats.Failed != 1 || stats.Spooled != 1 || stats.SpoolBytes == 0 || stats.Sent != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	c.down.Store(false)
+	l.Info("back")
+	_ = l.Sync()
+	stats = shipper.Stats()
+	if stats.Sent != 2 || stats.SpoolBytes != 0 || stats.Dropped != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+
+	bodies := c.received()
+	// the spooled batch is sent first.
+	if len(bodies) != 2 || !strings.Contains(bodies[0], `"msg":"lost"`) {
+		t.Fatalf("unexpected bodies %q", bodies)
+	}
+	lines := strings.Split(strings.TrimSpace(bodies[1]), "\n")
+	if len(lines) != 2 || lines[0] != `{"index":{"_index":"app"}}` || !strings.Contains(lines[1], `"msg":"back"`) {
+		t.Fatalf("unexpected bulk body %q", bodies[1])
+	}
+}
+
+func TestHTTPBackpressure(t *testing.T) {
+	entered := make(chan struct{})
+	gate := make(chan struct{})
+	var once sync.Once
+	c := &collector{}
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		once.Do(func() {
+			close(entered)
+			<-gate
+		})
+		c.ServeHTTP(w, r)
+	}))
+	defer server.Close()
+
+	shipper, err := NewHTTPShipper(HTTPConfig{
+		URL:           server.URL,
+		BatchSize:     1,
+		QueueSize:     1,
+		FlushInterval: time.Hour,
+		SpoolDir:      t.TempDir(),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shipper.Close()
+	enc, err := getEncoder("json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(NewHTTPCore(shipper, enc, zap.InfoLevel))
+
+	l.Info("sending")
+	<-entered
+	// queued, handed to the sender to spool, then dropped.
+	for i := 0; i < 4; i++ {
+		l.Info("behind")
+	}
+	if stats := shipper.Stats(); stats.Dropped != 2 || stats.Spooled != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+	close(gate)
+	_ = l.Sync()
+	if stats := shipper.Stats(); stats.Sent != 3 || stats.Spooled != 1 || stats.SpoolBytes != 0 || stats.Dropped != 2 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
+
+func TestHTTPSyncError(t *testing.T) {
+	gate := make(chan struct{})
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if calls.Add(1) == 1 {
+			w.WriteHeader(http.StatusServiceUnavailable)
+			return
+		}
+		<-gate
+	}))
+	defer server.Close()
+	defer close(gate)
+
+	shipper, err := NewHTTPShipper(HTTPConfig{
+		URL:           server.URL,
+		FlushInterval: time.Hour,
+		MaxRetries:    -1,
+		SyncTimeout:   100 * time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	enc, err := getEncoder("json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(NewHTTPCore(shipper, enc, zap.InfoLevel))
+
+	// the send error is returned.
+	l.Info("first")
+	err = shipper.Sync()
+	if err == nil || !strings.Contains(err.Error(), "503") {
+		t.Fatalf("unexpected error %v", err)
+	}
+
+	// a stalled collector does not block Sync past SyncTimeout.
+	l.Info("second")
+	start := time.Now()
+	err = shipper.Sync()
+	if err == nil || !strings.Contains(err.Error(), "timed out") || time.Since(start) > time.Second {
+		t.Fatalf("unexpected error %v after %s", err, time.Since(start))
+	}
+}
+
+func TestHTTPElasticsearchBulkErrors(t *testing.T) {
+	c := &collector{}
+	var calls atomic.Int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		c.ServeHTTP(w, r)
+		if calls.Add(1) == 1 {
+			_, _ = io.WriteString(w, `{"errors":true,"items":[`+
+				`{"index":{"status":201}},`+
+				`{"index":{"status":429,"error":{"type":"es_rejected_execution_exception"}}},`+
+				`{"index":{"status":400,"error":{"type":"mapper_parsing_exception"}}}]}`)
+			return
+		}
+		_, _ = io.WriteString(w, `{"errors":false,"items":[{"index":{"status":201}}]}`)
+	}))
+	defer server.Close()
+
+	shipper, err := NewHTTPShipper(HTTPConfig{
+		URL:           server.URL,
+		Format:        HTTPFormatElasticsearch,
+		FlushInterval: time.Hour,
+		MaxRetries:    1,
+		RetryBackoff:  time.Millisecond,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer shipper.Close()
+	enc, err := getEncoder("json", nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(NewHTTPCore(shipper, enc, zap.InfoLevel))
+	l.Info("indexed")
+	l.Info("rejected")
+	l.Info("invalid")
+	err = shipper.Sync()
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	// only the item rejected with 429 is retried.
+	bodies := c.received()
+	if len(bodies) != 2 || strings.Count(bodies[1], "\n") != 2 || !strings.Contains(bodies[1], `"msg":"rejected"`) {
+		t.Fatalf("unexpected bodies %q", bodies)
+	}
+	if stats := shipper.Stats(); stats.Sent != 2 || stats.Dropped != 1 || stats.Retries != 1 || stats.Failed != 0 {
+		t.Fatalf("unexpected stats %+v", stats)
+	}
+}
@@ -0,0 +1,157 @@
+package logutil
+
+import (
+	"bytes"
+	"encoding/binary"
+	"errors"
+	"net"
+	"os"
+	"path/filepath"
+	"strconv"
+	"strings"
+	"syscall"
+
+	"go.uber.org/zap/zapcore"
+)
+
+const defaultJournaldSocket = "/run/systemd/journal/socket"
+
+// JournaldConfig journald output config.
+type JournaldConfig struct {
+	Socket     string `json:"socket" yaml:"socket"`         // journald socket, 默认 /run/systemd/journal/socket
+	Identifier string `json:"identifier" yaml:"identifier"` // SYSLOG_IDENTIFIER, 默认进程名
+}
+
+// journaldCore write entries to journald with its native protocol. Fields
+// are journal fields with upper case names, e.g. user.id is USER_ID.
+type journaldCore struct {
+	zapcore.LevelEnabler
+	cfg    JournaldConfig
+	conn   *net.UnixConn
+	addr   *net.UnixAddr
+	fields []zapcore.Field
+}
+
+// NewJournaldCore new core writing to journald.
+func NewJournaldCore(cfg JournaldConfig, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+	if cfg.Socket == "" {
+		cfg.Socket = defaultJournaldSocket
+	}
+	if cfg.Identifier == "" {
+		cfg.Identifier = filepath.Base(os.Args[0])
+	}
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Net: "unixgram"})
+	if err != nil {
+		return nil, err
+	}
+	return &journaldCore{
+		LevelEnabler: enab,
+		cfg:          cfg,
+		conn:         conn,
+		addr:         &net.UnixAddr{Name: cfg.Socket, Net: "unixgram"},
+	}, nil
+}
+
+func (c *journaldCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &clone
+}
+
+func (c *journaldCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *journaldCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var buf bytes.Buffer
+	appendJournalField(&buf, "MESSAGE", ent.Message)
+	appendJournalField(&buf, "PRIORITY", strconv.Itoa(syslogSeverity(ent.Level)))
+	appendJournalField(&buf, "SYSLOG_IDENTIFIER", c.cfg.Identifier)
+	if ent.LoggerName != "" {
+		appendJournalField(&buf, "LOGGER", ent.LoggerName)
+	}
+	if ent.Caller.Defined {
+		appendJournalField(&buf, "CODE_FILE", ent.Caller.File)
+		appendJournalField(&buf, "CODE_LINE", strconv.Itoa(ent.Caller.Line))
+		if ent.Caller.Function != "" {
+			appendJournalField(&buf, "CODE_FUNC", ent.Caller.Function)
+		}
+	}
+	if ent.Stack != "" {
+		appendJournalField(&buf, "STACKTRACE", ent.Stack)
+	}
+	for _, f := range flattenFields(append(c.fields[:len(c.fields):len(c.fields)], fields...)) {
+		appendJournalField(&buf, journalFieldName(f.key), f.value)
+	}
+	return c.send(buf.Bytes())
+}
+
+func (c *journaldCore) Sync() error {
+	return nil
+}
+
+// Close close the journald socket.
+func (c *journaldCore) Close() error {
+	return c.conn.Close()
+}
+
+// send write the datagram, entries too large for a datagram are written to
+// an unlinked temporary file and its descriptor is sent instead.
+func (c *journaldCore) send(data []byte) error {
+	_, _, err := c.conn.WriteMsgUnix(data, nil, c.addr)
+	if err == nil || !(errors.Is(err, syscall.EMSGSIZE) || errors.Is(err, syscall.ENOBUFS)) {
+		return err
+	}
+
+	file, err := os.CreateTemp("", "journal-")
+	if err != nil {
+		return err
+	}
+	defer file.Close()
+	_ = os.Remove(file.Name())
+	_, err = file.Write(data)
+	if err != nil {
+		return err
+	}
+	_, _, err = c.conn.WriteMsgUnix(nil, syscall.UnixRights(int(file.Fd())), c.addr)
+	return err
+}
+
+// appendJournalField append KEY=value, values with newlines are KEY, the
+// little endian 64 bit length and the value.
+func appendJournalField(buf *bytes.Buffer, key, value string) {
+	buf.WriteString(key)
+	if !strings.Contains(value, "\n") {
+		buf.WriteByte('=')
+		buf.WriteString(value)
+		buf.WriteByte('\n')
+		return
+	}
+	buf.WriteByte('\n')
+	_ = binary.Write(buf, binary.LittleEndian, uint64(len(value)))
+	buf.WriteString(value)
+	buf.WriteByte('\n')
+}
+
+// journalFieldName returns the upper case journal field name of key, only
+// A-Z, 0-9 and '_' are allowed, it must not start with '_' or a digit and
+// is at most 64 characters.
+func journalFieldName(key string) string {
+	b := []byte(strings.ToUpper(key))
+	for i, c := range b {
+		if (c < 'A' || c > 'Z') && (c < '0' || c > '9') {
+			b[i] = '_'
+		}
+	}
+	name := strings.TrimLeft(string(b), "_")
+	if name == "" || (name[0] >= '0' && name[0] <= '9') {
+		name = "F_" + name
+	}
+	if len(name) > 64 {
+		name = name[:64]
+	}
+	return name
+}
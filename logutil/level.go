@@ -0,0 +1,87 @@
+package logutil
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"os/signal"
+	"strings"
+	"sync"
+	"syscall"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+var logLevel = map[string]zapcore.Level{
+	"debug":   zapcore.DebugLevel,
+	"info":    zapcore.InfoLevel,
+	"warn":    zapcore.WarnLevel,
+	"warning": zapcore.WarnLevel,
+	"error":   zapcore.ErrorLevel,
+	"dpanic":  zapcore.DPanicLevel,
+	"panic":   zapcore.PanicLevel,
+	"fatal":   zapcore.FatalLevel,
+}
+
+// parseLevel parse level name, case insensitive, empty means info.
+func parseLevel(name string) (zapcore.Level, error) {
+	if name == "" {
+		return zapcore.InfoLevel, nil
+	}
+	level, ok := logLevel[strings.ToLower(name)]
+	if !ok {
+		return zapcore.InfoLevel, fmt.Errorf("unknown log level %q", name)
+	}
+	return level, nil
+}
+
+// LevelHandler returns a http handler to get or change the level at runtime.
+// GET returns the current level, PUT changes it, e.g.
+//
+//	curl -X PUT -d '{"level":"debug"}' http://localhost:8080/log/level
+//	curl -X PUT -d 'level=debug' http://localhost:8080/log/level
+func LevelHandler(level zap.AtomicLevel) http.Handler {
+	return level
+}
+
+// WatchLevelSignals switch the level to debug on SIGUSR1 and restore the
+// previous level on SIGUSR2, call the returned func to stop watching.
+func WatchLevelSignals(level zap.AtomicLevel) (stop func()) {
+	ch := make(chan os.Signal, 1)
+	done := make(chan struct{})
+	signal.Notify(ch, syscall.SIGUSR1, syscall.SIGUSR2)
+
+	go func() {
+		previous := level.Level()
+		debug := false
+		for {
+			select {
+			case <-done:
+				return
+			case sig := <-ch:
+				switch sig {
+				case syscall.SIGUSR1:
+					if !debug {
+						previous = level.Level()
+						debug = true
+					}
+					level.SetLevel(zapcore.DebugLevel)
+				case syscall.SIGUSR2:
+					if debug {
+						level.SetLevel(previous)
+						debug = false
+					}
+				}
+			}
+		}
+	}()
+
+	var once sync.Once
+	return func() {
+		once.Do(func() {
+			signal.Stop(ch)
+			close(done)
+		})
+	}
+}
@@ -0,0 +1,90 @@
+package logutil
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"syscall"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestParseLevel(t *testing.T) {
+	for name, want := range map[string]zapcore.Level{
+		"":        zapcore.InfoLevel,
+		"DEBUG":   zapcore.DebugLevel,
+		"warning": zapcore.WarnLevel,
+		"Error":   zapcore.ErrorLevel,
+		"fatal":   zapcore.FatalLevel,
+	} {
+		level, err := parseLevel(name)
+		if err != nil || level != want {
+			t.Errorf("parseLevel(%q) = %s, %v", name, level, err)
+		}
+	}
+	if _, err := parseLevel("verbose"); err == nil {
+		t.Error("expected unknown level error")
+	}
+}
+
+func TestLevelHandler(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.InfoLevel)
+	handler := LevelHandler(level)
+
+	serve := func(method, body, contentType string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest(method, "/log/level", strings.NewReader(body))
+		if contentType != "" {
+			req.Header.Set("Content-Type", contentType)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	rec := serve(http.MethodGet, "", "")
+	if rec.Code != http.StatusOK || !strings.Contains(rec.Body.String(), `"level":"info"`) {
+		t.Fatalf("unexpected get %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(http.MethodPut, `{"level":"debug"}`, "application/json")
+	if rec.Code != http.StatusOK || level.Level() != zapcore.DebugLevel {
+		t.Fatalf("unexpected json put %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(http.MethodPut, "level=warn", "application/x-www-form-urlencoded")
+	if rec.Code != http.StatusOK || level.Level() != zapcore.WarnLevel {
+		t.Fatalf("unexpected form put %d %s", rec.Code, rec.Body)
+	}
+	rec = serve(http.MethodPut, `{"level":"verbose"}`, "application/json")
+	if rec.Code != http.StatusBadRequest || level.Level() != zapcore.WarnLevel {
+		t.Fatalf("unexpected invalid put %d %s", rec.Code, rec.Body)
+	}
+}
+
+func TestWatchLevelSignals(t *testing.T) {
+	level := zap.NewAtomicLevelAt(zapcore.WarnLevel)
+	stop := WatchLevelSignals(level)
+	defer stop()
+
+	waitLevel := func(want zapcore.Level) {
+		t.Helper()
+		for i := 0; i < 100 && level.Level() != want; i++ {
+			time.Sleep(10 * time.Millisecond)
+		}
+		if level.Level() != want {
+			t.Fatalf("level %s, want %s", level.Level(), want)
+		}
+	}
+
+	err := syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	if err != nil {
+		t.Fatal(err)
+	}
+	waitLevel(zapcore.DebugLevel)
+	// a second SIGUSR1 keeps the level to restore.
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGUSR1)
+	time.Sleep(20 * time.Millisecond)
+	_ = syscall.Kill(syscall.Getpid(), syscall.SIGUSR2)
+	waitLevel(zapcore.WarnLevel)
+}
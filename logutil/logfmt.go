@@ -0,0 +1,503 @@
+package logutil
+
+import (
+	"bytes"
+	"encoding/base64"
+	"encoding/json"
+	"math"
+	"sort"
+	"strconv"
+	"time"
+	"unicode/utf8"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+var logfmtPool = buffer.NewPool()
+
+// logfmtEncoder encode entries as logfmt, key=value pairs separated by space.
+// Nested objects and arrays are flattened with dotted keys, e.g.
+// user.name=foo tags.0=a tags.1=b.
+type logfmtEncoder struct {
+	*zapcore.EncoderConfig
+	buf    *buffer.Buffer
+	prefix string
+}
+
+// NewLogfmtEncoder new logfmt encoder.
+func NewLogfmtEncoder(cfg zapcore.EncoderConfig) zapcore.Encoder {
+	return &logfmtEncoder{
+		EncoderConfig: &cfg,
+		buf:           logfmtPool.Get(),
+	}
+}
+
+func (enc *logfmtEncoder) Clone() zapcore.Encoder {
+	clone := enc.clone()
+	_, _ = clone.buf.Write(enc.buf.Bytes())
+	return clone
+}
+
+func (enc *logfmtEncoder) clone() *logfmtEncoder {
+	return &logfmtEncoder{
+		EncoderConfig: enc.EncoderConfig,
+		buf:           logfmtPool.Get(),
+		prefix:        enc.prefix,
+	}
+}
+
+func (enc *logfmtEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := enc.clone()
+	final.prefix = ""
+
+	if final.TimeKey != "" {
+		final.AddTime(final.TimeKey, ent.Time)
+	}
+	if final.LevelKey != "" && final.EncodeLevel != nil {
+		final.addKey(final.LevelKey)
+		cur := final.buf.Len()
+		final.EncodeLevel(ent.Level, final)
+		if cur == final.buf.Len() {
+			final.AppendString(ent.Level.String())
+		}
+	}
+	if ent.LoggerName != "" && final.NameKey != "" {
+		final.addKey(final.NameKey)
+		cur := final.buf.Len()
+		nameEncoder := final.EncodeName
+		if nameEncoder == nil {
+			nameEncoder = zapcore.FullNameEncoder
+		}
+		nameEncoder(ent.LoggerName, final)
+		if cur == final.buf.Len() {
+			final.AppendString(ent.LoggerName)
+		}
+	}
+	if ent.Caller.Defined {
+		if final.CallerKey != "" && final.EncodeCaller != nil {
+			final.addKey(final.CallerKey)
+			cur := final.buf.Len()
+			final.EncodeCaller(ent.Caller, final)
+			if cur == final.buf.Len() {
+				final.AppendString(ent.Caller.String())
+			}
+		}
+		if final.FunctionKey != "" {
+			final.addKey(final.FunctionKey)
+			final.AppendString(ent.Caller.Function)
+		}
+	}
+	if final.MessageKey != "" {
+		final.addKey(final.MessageKey)
+		final.AppendString(ent.Message)
+	}
+	if enc.buf.Len() > 0 {
+		final.addSeparator()
+		_, _ = final.buf.Write(enc.buf.Bytes())
+	}
+
+	final.prefix = enc.prefix
+	for i := range fields {
+		fields[i].AddTo(final)
+	}
+	final.prefix = ""
+
+	if ent.Stack != "" && final.StacktraceKey != "" {
+		final.AddString(final.StacktraceKey, ent.Stack)
+	}
+	if !final.SkipLineEnding {
+		if final.LineEnding != "" {
+			final.buf.AppendString(final.LineEnding)
+		} else {
+			final.buf.AppendString(zapcore.DefaultLineEnding)
+		}
+	}
+	return final.buf, nil
+}
+
+func (enc *logfmtEncoder) addSeparator() {
+	if enc.buf.Len() > 0 {
+		enc.buf.AppendByte(' ')
+	}
+}
+
+func (enc *logfmtEncoder) addKey(key string) {
+	enc.addSeparator()
+	if enc.prefix != "" {
+		enc.safeAddKey(enc.prefix)
+		enc.buf.AppendByte('.')
+	}
+	enc.safeAddKey(key)
+	enc.buf.AppendByte('=')
+}
+
+// safeAddKey write key, characters not allowed in keys are replaced with '_'.
+func (enc *logfmtEncoder) safeAddKey(key string) {
+	if key == "" {
+		enc.buf.AppendByte('_')
+		return
+	}
+	for _, r := range key {
+		if r <= ' ' || r == '=' || r == '"' || r == utf8.RuneError || r == 0x7f {
+			enc.buf.AppendByte('_')
+			continue
+		}
+		enc.buf.AppendString(string(r))
+	}
+}
+
+// nested call fn with key appended to the key prefix.
+func (enc *logfmtEncoder) nested(key string, fn func() error) error {
+	prefix := enc.prefix
+	if prefix == "" {
+		enc.prefix = key
+	} else {
+		enc.prefix = prefix + "." + key
+	}
+	err := fn()
+	enc.prefix = prefix
+	return err
+}
+
+func (enc *logfmtEncoder) AddArray(key string, arr zapcore.ArrayMarshaler) error {
+	return enc.nested(key, func() error {
+		ae := &logfmtArrayEncoder{enc: enc}
+		err := arr.MarshalLogArray(ae)
+		if err == nil && ae.index == 0 {
+			enc.addEmpty("[]")
+		}
+		return err
+	})
+}
+
+func (enc *logfmtEncoder) AddObject(key string, obj zapcore.ObjectMarshaler) error {
+	return enc.nested(key, func() error {
+		return obj.MarshalLogObject(enc)
+	})
+}
+
+// addEmpty write the current prefix with an empty value like [] or {}.
+func (enc *logfmtEncoder) addEmpty(value string) {
+	prefix := enc.prefix
+	enc.prefix = ""
+	enc.addKey(prefix)
+	enc.prefix = prefix
+	enc.buf.AppendString(value)
+}
+
+func (enc *logfmtEncoder) AddBinary(key string, val []byte) {
+	enc.AddString(key, base64.StdEncoding.EncodeToString(val))
+}
+
+func (enc *logfmtEncoder) AddByteString(key string, val []byte) {
+	enc.addKey(key)
+	enc.AppendByteString(val)
+}
+
+func (enc *logfmtEncoder) AddBool(key string, val bool) {
+	enc.addKey(key)
+	enc.AppendBool(val)
+}
+
+func (enc *logfmtEncoder) AddComplex128(key string, val complex128) {
+	enc.addKey(key)
+	enc.AppendComplex128(val)
+}
+
+func (enc *logfmtEncoder) AddComplex64(key string, val complex64) {
+	enc.AddComplex128(key, complex128(val))
+}
+
+func (enc *logfmtEncoder) AddDuration(key string, val time.Duration) {
+	enc.addKey(key)
+	enc.AppendDuration(val)
+}
+
+func (enc *logfmtEncoder) AddFloat64(key string, val float64) {
+	enc.addKey(key)
+	enc.AppendFloat64(val)
+}
+
+func (enc *logfmtEncoder) AddFloat32(key string, val float32) {
+	enc.addKey(key)
+	enc.AppendFloat32(val)
+}
+
+func (enc *logfmtEncoder) AddInt(key string, val int)     { enc.AddInt64(key, int64(val)) }
+func (enc *logfmtEncoder) AddInt32(key string, val int32) { enc.AddInt64(key, int64(val)) }
+func (enc *logfmtEncoder) AddInt16(key string, val int16) { enc.AddInt64(key, int64(val)) }
+func (enc *logfmtEncoder) AddInt8(key string, val int8)   { enc.AddInt64(key, int64(val)) }
+
+func (enc *logfmtEncoder) AddInt64(key string, val int64) {
+	enc.addKey(key)
+	enc.AppendInt64(val)
+}
+
+func (enc *logfmtEncoder) AddUint(key string, val uint)       { enc.AddUint64(key, uint64(val)) }
+func (enc *logfmtEncoder) AddUint32(key string, val uint32)   { enc.AddUint64(key, uint64(val)) }
+func (enc *logfmtEncoder) AddUint16(key string, val uint16)   { enc.AddUint64(key, uint64(val)) }
+func (enc *logfmtEncoder) AddUint8(key string, val uint8)     { enc.AddUint64(key, uint64(val)) }
+func (enc *logfmtEncoder) AddUintptr(key string, val uintptr) { enc.AddUint64(key, uint64(val)) }
+
+func (enc *logfmtEncoder) AddUint64(key string, val uint64) {
+	enc.addKey(key)
+	enc.AppendUint64(val)
+}
+
+func (enc *logfmtEncoder) AddReflected(key string, obj interface{}) error {
+	data, err := json.Marshal(obj)
+	if err != nil {
+		return err
+	}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	var value interface{}
+	err = decoder.Decode(&value)
+	if err != nil {
+		return err
+	}
+	enc.addValue(key, value)
+	return nil
+}
+
+// addValue flatten the decoded json value.
+func (enc *logfmtEncoder) addValue(key string, value interface{}) {
+	switch v := value.(type) {
+	case map[string]interface{}:
+		_ = enc.nested(key, func() error {
+			if len(v) == 0 {
+				enc.addEmpty("{}")
+				return nil
+			}
+			keys := make([]string, 0, len(v))
+			for k := range v {
+				keys = append(keys, k)
+			}
+			sort.Strings(keys)
+			for _, k := range keys {
+				enc.addValue(k, v[k])
+			}
+			return nil
+		})
+	case []interface{}:
+		_ = enc.nested(key, func() error {
+			if len(v) == 0 {
+				enc.addEmpty("[]")
+				return nil
+			}
+			for i, item := range v {
+				enc.addValue(strconv.Itoa(i), item)
+			}
+			return nil
+		})
+	case string:
+		enc.AddString(key, v)
+	case json.Number:
+		enc.addKey(key)
+		enc.buf.AppendString(v.String())
+	case bool:
+		enc.AddBool(key, v)
+	case nil:
+		enc.addKey(key)
+		enc.buf.AppendString("null")
+	}
+}
+
+func (enc *logfmtEncoder) AddString(key, val string) {
+	enc.addKey(key)
+	enc.AppendString(val)
+}
+
+func (enc *logfmtEncoder) AddTime(key string, val time.Time) {
+	enc.addKey(key)
+	enc.AppendTime(val)
+}
+
+func (enc *logfmtEncoder) OpenNamespace(key string) {
+	if enc.prefix == "" {
+		enc.prefix = key
+		return
+	}
+	enc.prefix = enc.prefix + "." + key
+}
+
+// Append methods write the value at the current position, they are used by
+// the level, time, caller and duration encoders of EncoderConfig.
+
+func (enc *logfmtEncoder) AppendBool(val bool) {
+	enc.buf.AppendBool(val)
+}
+
+func (enc *logfmtEncoder) AppendByteString(val []byte) {
+	enc.appendQuoted(string(val))
+}
+
+func (enc *logfmtEncoder) AppendComplex128(val complex128) {
+	r, i := real(val), imag(val)
+	enc.buf.AppendFloat(r, 64)
+	if i >= 0 {
+		enc.buf.AppendByte('+')
+	}
+	enc.buf.AppendFloat(i, 64)
+	enc.buf.AppendByte('i')
+}
+
+func (enc *logfmtEncoder) AppendComplex64(val complex64) {
+	enc.AppendComplex128(complex128(val))
+}
+
+func (enc *logfmtEncoder) AppendDuration(val time.Duration) {
+	cur := enc.buf.Len()
+	if enc.EncodeDuration != nil {
+		enc.EncodeDuration(val, enc)
+	}
+	if cur == enc.buf.Len() {
+		enc.AppendString(val.String())
+	}
+}
+
+func (enc *logfmtEncoder) AppendFloat64(val float64) { enc.appendFloat(val, 64) }
+func (enc *logfmtEncoder) AppendFloat32(val float32) { enc.appendFloat(float64(val), 32) }
+
+func (enc *logfmtEncoder) appendFloat(val float64, bitSize int) {
+	switch {
+	case math.IsNaN(val):
+		enc.buf.AppendString("NaN")
+	case math.IsInf(val, 1):
+		enc.buf.AppendString("+Inf")
+	case math.IsInf(val, -1):
+		enc.buf.AppendString("-Inf")
+	default:
+		enc.buf.AppendFloat(val, bitSize)
+	}
+}
+
+func (enc *logfmtEncoder) AppendInt(val int)     { enc.AppendInt64(int64(val)) }
+func (enc *logfmtEncoder) AppendInt32(val int32) { enc.AppendInt64(int64(val)) }
+func (enc *logfmtEncoder) AppendInt16(val int16) { enc.AppendInt64(int64(val)) }
+func (enc *logfmtEncoder) AppendInt8(val int8)   { enc.AppendInt64(int64(val)) }
+
+func (enc *logfmtEncoder) AppendInt64(val int64) {
+	enc.buf.AppendInt(val)
+}
+
+func (enc *logfmtEncoder) AppendUint(val uint)       { enc.AppendUint64(uint64(val)) }
+func (enc *logfmtEncoder) AppendUint32(val uint32)   { enc.AppendUint64(uint64(val)) }
+func (enc *logfmtEncoder) AppendUint16(val uint16)   { enc.AppendUint64(uint64(val)) }
+func (enc *logfmtEncoder) AppendUint8(val uint8)     { enc.AppendUint64(uint64(val)) }
+func (enc *logfmtEncoder) AppendUintptr(val uintptr) { enc.AppendUint64(uint64(val)) }
+
+func (enc *logfmtEncoder) AppendUint64(val uint64) {
+	enc.buf.AppendUint(val)
+}
+
+func (enc *logfmtEncoder) AppendString(val string) {
+	enc.appendQuoted(val)
+}
+
+func (enc *logfmtEncoder) AppendTime(val time.Time) {
+	cur := enc.buf.Len()
+	if enc.EncodeTime != nil {
+		enc.EncodeTime(val, enc)
+	}
+	if cur == enc.buf.Len() {
+		enc.buf.AppendTime(val, time.RFC3339Nano)
+	}
+}
+
+// appendQuoted write the value, quote and escape it when it has spaces,
+// '=', '"', control characters or invalid utf8.
+func (enc *logfmtEncoder) appendQuoted(val string) {
+	if !needsQuote(val) {
+		enc.buf.AppendString(val)
+		return
+	}
+
+	enc.buf.AppendByte('"')
+	for i := 0; i < len(val); {
+		r, size := utf8.DecodeRuneInString(val[i:])
+		switch {
+		case r == '"' || r == '\\':
+			enc.buf.AppendByte('\\')
+			enc.buf.AppendByte(byte(r))
+		case r == '\n':
+			enc.buf.AppendString(`\n`)
+		case r == '\r':
+			enc.buf.AppendString(`\r`)
+		case r == '\t':
+			enc.buf.AppendString(`\t`)
+		case r == utf8.RuneError && size == 1:
+			enc.buf.AppendString("\ufffd")
+		case r < ' ' || r == 0x7f:
+			enc.buf.AppendString(`\u00`)
+			enc.buf.AppendByte(hexDigits[byte(r)>>4])
+			enc.buf.AppendByte(hexDigits[byte(r)&0xf])
+		default:
+			enc.buf.AppendString(val[i : i+size])
+		}
+		i += size
+	}
+	enc.buf.AppendByte('"')
+}
+
+const hexDigits = "0123456789abcdef"
+
+func needsQuote(val string) bool {
+	if val == "" {
+		return true
+	}
+	for i := 0; i < len(val); {
+		r, size := utf8.DecodeRuneInString(val[i:])
+		if r <= ' ' || r == '=' || r == '"' || r == '\\' || r == 0x7f || (r == utf8.RuneError && size == 1) {
+			return true
+		}
+		i += size
+	}
+	return false
+}
+
+// logfmtArrayEncoder write array elements with their index as key.
+type logfmtArrayEncoder struct {
+	enc   *logfmtEncoder
+	index int
+}
+
+func (ae *logfmtArrayEncoder) key() string {
+	key := strconv.Itoa(ae.index)
+	ae.index++
+	return key
+}
+
+func (ae *logfmtArrayEncoder) AppendArray(arr zapcore.ArrayMarshaler) error {
+	return ae.enc.AddArray(ae.key(), arr)
+}
+
+func (ae *logfmtArrayEncoder) AppendObject(obj zapcore.ObjectMarshaler) error {
+	return ae.enc.AddObject(ae.key(), obj)
+}
+
+func (ae *logfmtArrayEncoder) AppendReflected(val interface{}) error {
+	return ae.enc.AddReflected(ae.key(), val)
+}
+
+func (ae *logfmtArrayEncoder) AppendBool(val bool)              { ae.enc.AddBool(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendByteString(val []byte)      { ae.enc.AddByteString(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendComplex128(val complex128)  { ae.enc.AddComplex128(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendComplex64(val complex64)    { ae.enc.AddComplex64(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendDuration(val time.Duration) { ae.enc.AddDuration(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendFloat64(val float64)        { ae.enc.AddFloat64(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendFloat32(val float32)        { ae.enc.AddFloat32(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendInt(val int)                { ae.enc.AddInt(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendInt64(val int64)            { ae.enc.AddInt64(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendInt32(val int32)            { ae.enc.AddInt32(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendInt16(val int16)            { ae.enc.AddInt16(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendInt8(val int8)              { ae.enc.AddInt8(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendString(val string)          { ae.enc.AddString(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendTime(val time.Time)         { ae.enc.AddTime(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendUint(val uint)              { ae.enc.AddUint(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendUint64(val uint64)          { ae.enc.AddUint64(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendUint32(val uint32)          { ae.enc.AddUint32(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendUint16(val uint16)          { ae.enc.AddUint16(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendUint8(val uint8)            { ae.enc.AddUint8(ae.key(), val) }
+func (ae *logfmtArrayEncoder) AppendUintptr(val uintptr)        { ae.enc.AddUintptr(ae.key(), val) }
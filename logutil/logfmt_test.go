@@ -0,0 +1,66 @@
+package logutil
+
+import (
+	"errors"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type logfmtUser struct {
+	Name  string
+	Roles []string
+}
+
+func (u logfmtUser) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddString("name", u.Name)
+	return enc.AddArray("roles", zapcore.ArrayMarshalerFunc(func(ae zapcore.ArrayEncoder) error {
+		for _, role := range u.Roles {
+			ae.AppendString(role)
+		}
+		return nil
+	}))
+}
+
+func TestLogfmtEncoder(t *testing.T) {
+	cfg := zapcore.EncoderConfig{
+		TimeKey:     "ts",
+		LevelKey:    "level",
+		NameKey:     "logger",
+		MessageKey:  "msg",
+		EncodeTime:  zapcore.RFC3339TimeEncoder,
+		EncodeLevel: zapcore.LowercaseLevelEncoder,
+	}
+	enc := NewLogfmtEncoder(cfg)
+	enc.AddString("app", "demo")
+
+	ent := zapcore.Entry{
+		Level:      zapcore.InfoLevel,
+		Time:       time.Date(2026, 10, 17, 8, 0, 0, 0, time.UTC),
+		LoggerName: "db",
+		Message:    `say "hi"`,
+	}
+	fields := []zapcore.Field{
+		zap.String("empty", ""),
+		zap.String("path", "a=b c"),
+		zap.Int("count", 3),
+		zap.Error(errors.New("line1\nline2")),
+		zap.Object("user", logfmtUser{Name: "foo", Roles: []string{"admin", "dev"}}),
+		zap.Any("meta", map[string]interface{}{"k": []int{1}, "ok": true}),
+		zap.Strings("none", nil),
+		zap.Namespace("req"),
+		zap.String("id", "1"),
+	}
+	buf, err := enc.EncodeEntry(ent, fields)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	want := `ts=2026-10-17T08:00:00Z level=info logger=db msg="say \"hi\"" app=demo empty="" path="a=b c" count=3 ` +
+		`error="line1\nline2" user.name=foo user.roles.0=admin user.roles.1=dev meta.k.0=1 meta.ok=true none=[] req.id=1` + "\n"
+	if buf.String() != want {
+		t.Errorf("got  %s\nwant %s", buf.String(), want)
+	}
+}
@@ -0,0 +1,41 @@
+// Package logtest provides test helpers for code logging through logutil.
+package logtest
+
+import (
+	"os"
+	"strings"
+	"testing"
+)
+
+// AssertNoLeak fails the test if any secret appears in the log output.
+func AssertNoLeak(t testing.TB, output string, secrets ...string) {
+	t.Helper()
+	for _, secret := range secrets {
+		if secret == "" {
+			continue
+		}
+		if i := strings.Index(output, secret); i >= 0 {
+			t.Errorf("secret %q leaked in log output: %s", secret, line(output, i))
+		}
+	}
+}
+
+// AssertNoLeakInFile fails the test if any secret appears in the log file.
+func AssertNoLeakInFile(t testing.TB, path string, secrets ...string) {
+	t.Helper()
+	data, err := os.ReadFile(path)
+	if err != nil {
+		t.Fatalf("read log file %s: %v", path, err)
+	}
+	AssertNoLeak(t, string(data), secrets...)
+}
+
+// line returns the line of output containing offset i.
+func line(output string, i int) string {
+	start := strings.LastIndexByte(output[:i], '\n') + 1
+	end := strings.IndexByte(output[i:], '\n')
+	if end < 0 {
+		return output[start:]
+	}
+	return output[start : i+end]
+}
@@ -0,0 +1,120 @@
+package logtest
+
+import (
+	"fmt"
+	"strings"
+	"testing"
+
+	"github.com/booyangcc/utils/logutil"
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type options struct {
+	level   zapcore.Level
+	testLog bool
+}
+
+// Option observer option.
+type Option func(*options)
+
+// WithLevel with the lowest observed level, default debug.
+func WithLevel(level zapcore.Level) Option {
+	return func(o *options) {
+		o.level = level
+	}
+}
+
+// WithTestLog with entries also written to t.Log, they are shown when the
+// test fails or runs with -v.
+func WithTestLog() Option {
+	return func(o *options) {
+		o.testLog = true
+	}
+}
+
+// Observed entries logged by the logger of NewObserver.
+type Observed struct {
+	t    testing.TB
+	logs *observer.ObservedLogs
+}
+
+// NewObserver returns a logger keeping its entries in memory and the
+// Observed to assert them.
+func NewObserver(t testing.TB, opts ...Option) (*zap.Logger, *Observed) {
+	o := &options{level: zapcore.DebugLevel}
+	for _, opt := range opts {
+		opt(o)
+	}
+
+	core, logs := observer.New(o.level)
+	if o.testLog {
+		core = zapcore.NewTee(core, zaptest.NewLogger(t, zaptest.Level(o.level)).Core())
+	}
+	return zap.New(core), &Observed{t: t, logs: logs}
+}
+
+// Entries returns the observed entries.
+func (o *Observed) Entries() []observer.LoggedEntry {
+	return o.logs.All()
+}
+
+// Reset remove the observed entries.
+func (o *Observed) Reset() {
+	o.logs.TakeAll()
+}
+
+// Count returns the number of entries at level.
+func (o *Observed) Count(level zapcore.Level) int {
+	n := 0
+	for _, ent := range o.logs.All() {
+		if ent.Level == level {
+			n++
+		}
+	}
+	return n
+}
+
+// AssertMessage fails the test if no entry at level contains msg.
+func (o *Observed) AssertMessage(level zapcore.Level, msg string) {
+	o.t.Helper()
+	for _, ent := range o.logs.All() {
+		if ent.Level == level && strings.Contains(ent.Message, msg) {
+			return
+		}
+	}
+	o.t.Errorf("no %s entry contains message %q, got:\n%s", level, msg, o.dump())
+}
+
+// AssertField fails the test if no entry has the field key with value,
+// values are compared by their fmt.Sprint strings, nested fields use dotted
+// keys like user.id.
+func (o *Observed) AssertField(key string, value interface{}) {
+	o.t.Helper()
+	want := fmt.Sprint(value)
+	for _, ent := range o.logs.All() {
+		if got, ok := logutil.LookupField(ent.ContextMap(), key); ok && fmt.Sprint(got) == want {
+			return
+		}
+	}
+	o.t.Errorf("no entry has field %s=%v, got:\n%s", key, value, o.dump())
+}
+
+// AssertCount fails the test if the number of entries at level is not n.
+func (o *Observed) AssertCount(level zapcore.Level, n int) {
+	o.t.Helper()
+	if got := o.Count(level); got != n {
+		o.t.Errorf("got %d %s entries, want %d:\n%s", got, level, n, o.dump())
+	}
+}
+
+// dump returns the observed entries, one per line.
+func (o *Observed) dump() string {
+	var b strings.Builder
+	for _, ent := range o.logs.All() {
+		fmt.Fprintf(&b, "\t%s %q %v\n", ent.Level, ent.Message, ent.ContextMap())
+	}
+	return b.String()
+}
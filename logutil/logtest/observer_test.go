@@ -0,0 +1,54 @@
+package logtest
+
+import (
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+type user struct {
+	ID int
+}
+
+func (u user) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	enc.AddInt("id", u.ID)
+	return nil
+}
+
+func TestObserver(t *testing.T) {
+	l, observed := NewObserver(t, WithLevel(zapcore.InfoLevel), WithTestLog())
+	l.Debug("ignored")
+	l.Info("user created", zap.Object("user", user{ID: 42}))
+	l.Warn("slow query", zap.Duration("took", 0))
+	l.Warn("slow query again")
+
+	observed.AssertMessage(zapcore.InfoLevel, "created")
+	observed.AssertField("user.id", 42)
+	observed.AssertCount(zapcore.WarnLevel, 2)
+	observed.AssertCount(zapcore.DebugLevel, 0)
+
+	ft := &fakeT{TB: t}
+	failing := &Observed{t: ft, logs: observed.logs}
+	failing.AssertMessage(zapcore.ErrorLevel, "created")
+	failing.AssertField("user.id", 1)
+	failing.AssertCount(zapcore.InfoLevel, 2)
+	if ft.errors != 3 {
+		t.Fatalf("expected 3 failures, got %d", ft.errors)
+	}
+
+	observed.Reset()
+	if len(observed.Entries()) != 0 {
+		t.Fatal("entries not reset")
+	}
+}
+
+// fakeT records errors instead of failing the test.
+type fakeT struct {
+	testing.TB
+	errors int
+}
+
+func (t *fakeT) Errorf(string, ...interface{}) {
+	t.errors++
+}
@@ -1,6 +1,8 @@
 package logutil
 
 import (
+	"errors"
+	"fmt"
 	"os"
 	"path/filepath"
 
@@ -25,17 +27,31 @@ const (
 
 // Config config
 type Config struct {
-	LogLevel          string // 日志打印级别 debug  info  warning  error
-	LogFormat         string // 输出日志格式	logfmt, json
-	LogPath           string // 输出日志文件路径
-	LogFileName       string // 输出日志文件名称
-	LogFileMaxSize    int    // 【日志分割】单个日志文件最多存储量 单位(mb)
-	LogFileMaxBackups int    // 【日志分割】日志备份文件最多数量
-	LogMaxAge         int    // 日志保留时间，单位: 天 (day)
-	LogCompress       bool   // 是否压缩日志
-	LogStdout         bool   // 是否输出到控制台
-	LogType           int
-	Caller            bool //是否输出调用链路
+	LogLevel          string            `json:"log_level" yaml:"log_level"`                       // 日志打印级别 debug  info  warning  error
+	LogFormat         string            `json:"log_format" yaml:"log_format"`                     // 输出日志格式	console, logfmt, json, pretty
+	LogPath           string            `json:"log_path" yaml:"log_path"`                         // 输出日志文件路径
+	LogFileName       string            `json:"log_file_name" yaml:"log_file_name"`               // 输出日志文件名称
+	LogFileMaxSize    int               `json:"log_file_max_size" yaml:"log_file_max_size"`       // 【日志分割】单个日志文件最多存储量 单位(mb)
+	LogFileMaxBackups int               `json:"log_file_max_backups" yaml:"log_file_max_backups"` // 【日志分割】日志备份文件最多数量
+	LogMaxAge         int               `json:"log_max_age" yaml:"log_max_age"`                   // 日志保留时间，单位: 天 (day)
+	LogCompress       bool              `json:"log_compress" yaml:"log_compress"`                 // 是否压缩日志
+	LogRotate         string            `json:"log_rotate" yaml:"log_rotate"`                     // 【日志分割】分割方式 size daily hourly, 默认 size
+	LogStdout         bool              `json:"log_stdout" yaml:"log_stdout"`                     // 是否输出到控制台
+	LogType           int               `json:"log_type" yaml:"log_type"`
+	Caller            bool              `json:"caller" yaml:"caller"`                       // 是否输出调用链路
+	LogModuleLevels   string            `json:"log_module_levels" yaml:"log_module_levels"` // 模块日志级别, 如 db=debug,http=warn
+	Level             zap.AtomicLevel   `json:"-" yaml:"-"`                                 // 运行时可调整的日志级别, 为空时由New创建
+	ModuleLevels      *ModuleLevels     `json:"-" yaml:"-"`                                 // 运行时可调整的模块日志级别, 为空时由New创建
+	Outputs           []Output          `json:"outputs" yaml:"outputs"`                     // 日志输出列表, 为空时按 LogType 输出
+	Async             *AsyncConfig      `json:"async" yaml:"async"`                         // 异步写入配置, 为空时同步写入
+	Redact            *RedactConfig     `json:"redact" yaml:"redact"`                       // 脱敏配置, 为空时不脱敏
+	Sampling          []SamplingConfig  `json:"sampling" yaml:"sampling"`                   // 按级别采样配置, 为空时不采样
+	RateLimit         []RateLimitConfig `json:"rate_limit" yaml:"rate_limit"`               // 按级别限流配置, 为空时不限流
+	Hook              *HookConfig       `json:"hook" yaml:"hook"`                           // 错误日志 hook 配置, 为空时不触发
+	Metrics           *Metrics          `json:"-" yaml:"-"`                                 // 日志统计, 为空时不统计
+	Encoding          *EncodingConfig   `json:"encoding" yaml:"encoding"`                   // 时间 级别格式和字段名配置, 为空时使用默认格式
+	CallerSkip        int               `json:"caller_skip" yaml:"caller_skip"`             // 调用位置跳过的层数, 封装日志函数时设置
+	StacktraceLevel   string            `json:"stacktrace_level" yaml:"stacktrace_level"`   // 输出堆栈的最低级别, 为空时不输出
 }
 
 var defaultConfig = &Config{
@@ -110,6 +126,13 @@ func WithLogCompress(logCompress bool) Option {
 	}
 }
 
+// WithLogRotate with log rotate mode, size daily or hourly.
+func WithLogRotate(rotate string) Option {
+	return func(lc *Config) {
+		lc.LogRotate = rotate
+	}
+}
+
 // WithStdout with stdout.
 func WithStdout(isStdout bool) Option {
 	return func(lc *Config) {
@@ -131,86 +154,360 @@ func WithCaller(enabled bool) Option {
 	}
 }
 
-// New new
-func New(cfg *Config, opts ...Option) (*zap.Logger, error) {
-	if cfg == nil {
-		cfg = defaultConfig
+// WithAtomicLevel with atomic level, the level is set to LogLevel and can be changed at runtime.
+func WithAtomicLevel(level zap.AtomicLevel) Option {
+	return func(lc *Config) {
+		lc.Level = level
 	}
+}
 
-	for _, opt := range opts {
-		opt(cfg)
+// WithOutputs with outputs, replaces LogType.
+func WithOutputs(outputs ...Output) Option {
+	return func(lc *Config) {
+		lc.Outputs = outputs
+	}
+}
+
+// WithAsync with async write, logs are buffered and written in background,
+// call Sync on the logger before exit to flush them.
+func WithAsync(async AsyncConfig) Option {
+	return func(lc *Config) {
+		lc.Async = &async
+	}
+}
+
+// WithRedact with redact, sensitive fields and values are masked or hashed
+// in all outputs.
+func WithRedact(redact RedactConfig) Option {
+	return func(lc *Config) {
+		lc.Redact = &redact
+	}
+}
+
+// WithSampling with sampling, e.g. the first 100 then every 100th entries with
+// the same message per second.
+func WithSampling(sampling ...SamplingConfig) Option {
+	return func(lc *Config) {
+		lc.Sampling = sampling
+	}
+}
+
+// WithRateLimit with rate limit, entries over the limit are suppressed and
+// reported by a summary entry.
+func WithRateLimit(rateLimit ...RateLimitConfig) Option {
+	return func(lc *Config) {
+		lc.RateLimit = rateLimit
+	}
+}
+
+// WithHook with hook config, error entries call the hooks in background.
+func WithHook(hook HookConfig) Option {
+	return func(lc *Config) {
+		lc.Hook = &hook
+	}
+}
+
+// WithHooks with hooks called for error entries, they are added to the hook
+// config.
+func WithHooks(hooks ...Hook) Option {
+	return func(lc *Config) {
+		if lc.Hook == nil {
+			lc.Hook = &HookConfig{}
+		} else {
+			hook := *lc.Hook
+			lc.Hook = &hook
+		}
+		lc.Hook.Hooks = append(lc.Hook.Hooks[:len(lc.Hook.Hooks):len(lc.Hook.Hooks)], hooks...)
+	}
+}
+
+// WithMetrics with metrics counting the entries by level and logger, and the
+// dropped entries.
+func WithMetrics(metrics *Metrics) Option {
+	return func(lc *Config) {
+		lc.Metrics = metrics
+	}
+}
+
+// WithEncoding with the time layout, time zone, level encoding and keys.
+func WithEncoding(encoding EncodingConfig) Option {
+	return func(lc *Config) {
+		lc.Encoding = &encoding
+	}
+}
+
+// WithCallerSkip with the number of frames skipped for the caller, set it when
+// logging through wrapper functions.
+func WithCallerSkip(skip int) Option {
+	return func(lc *Config) {
+		lc.CallerSkip = skip
+	}
+}
+
+// WithStacktraceLevel with stacktraces added to entries at or above level.
+func WithStacktraceLevel(level string) Option {
+	return func(lc *Config) {
+		lc.StacktraceLevel = level
 	}
-	log, err := initLogger(cfg)
+}
+
+// WithLogModuleLevels with module levels spec, e.g. "db=debug,http=warn".
+func WithLogModuleLevels(spec string) Option {
+	return func(lc *Config) {
+		lc.LogModuleLevels = spec
+	}
+}
+
+// WithModuleLevels with module levels, LogModuleLevels is applied to it and
+// its root level is used as the atomic level.
+func WithModuleLevels(levels *ModuleLevels) Option {
+	return func(lc *Config) {
+		lc.ModuleLevels = levels
+	}
+}
+
+// New new
+func New(cfg *Config, opts ...Option) (*zap.Logger, error) {
+	log, _, err := NewWithLevel(cfg, opts...)
 	if err != nil {
 		return nil, err
 	}
 	return log, nil
 }
 
-// GetLoggerFullPath get log full path.
+// NewWithLevel new logger and return the atomic level handle used to change the level at runtime.
+func NewWithLevel(cfg *Config, opts ...Option) (*zap.Logger, zap.AtomicLevel, error) {
+	log, level, s, err := newLogger(cfg, opts...)
+	if err != nil {
+		return nil, level, err
+	}
+	lastSinks.Store(s)
+	return log, level, nil
+}
+
+// GetLoggerFullPath get log full path, it is the first log file of the
+// default registry, or of the logger created last when the registry has no
+// file outputs.
 func GetLoggerFullPath() string {
+	if paths := LogFilePaths(); len(paths) > 0 {
+		return paths[0]
+	}
+	if s := lastSinks.Load(); s != nil {
+		if paths := s.filePaths(); len(paths) > 0 {
+			return paths[0]
+		}
+	}
 	return filepath.Join(defaultConfig.LogPath, defaultConfig.LogFileName)
 }
 
-func initLogger(cfg *Config) (*zap.Logger, error) {
-	logLevel := map[string]zapcore.Level{
-		"debug": zapcore.DebugLevel,
-		"info":  zapcore.InfoLevel,
-		"warn":  zapcore.WarnLevel,
-		"error": zapcore.ErrorLevel,
+func newLogger(cfg *Config, opts ...Option) (*zap.Logger, zap.AtomicLevel, *sinks, error) {
+	if cfg == nil {
+		c := *defaultConfig
+		cfg = &c
+	}
+
+	for _, opt := range opts {
+		opt(cfg)
+	}
+
+	levels := cfg.ModuleLevels
+	if levels == nil {
+		level := cfg.Level
+		if level == (zap.AtomicLevel{}) {
+			level = zap.NewAtomicLevel()
+		}
+		levels = NewModuleLevels(level)
 	}
-	writeSyncer, err := getLogWriter(cfg)
+	level := levels.Root()
+	err := cfg.Validate()
 	if err != nil {
-		return nil, err
+		return nil, level, nil, err
 	}
-	encoder := getEncoder(cfg)
-	level, ok := logLevel[cfg.LogLevel]
-	if !ok {
-		level = logLevel["info"]
+	err = levels.Apply(cfg)
+	if err != nil {
+		return nil, level, nil, err
 	}
 
-	core := zapcore.NewCore(encoder, writeSyncer, level)
-	var l *zap.Logger
-	if !cfg.Caller {
-		l = zap.New(core)
-	} else {
-		l = zap.New(core, zap.AddCaller())
+	log, s, err := initLogger(cfg, levels)
+	if err != nil {
+		return nil, level, nil, err
 	}
-
-	return l, nil
+	return log, level, s, nil
 }
 
-// getEncoder 编码器(如何写入日志)
-func getEncoder(cfg *Config) zapcore.Encoder {
-	encoderConfig := zap.NewProductionEncoderConfig()
-	encoderConfig.EncodeTime = zapcore.ISO8601TimeEncoder   // log 时间格式 例如: 2021-09-11t20:05:54.852+0800
-	encoderConfig.EncodeLevel = zapcore.CapitalLevelEncoder // 输出level序列化为全大写字符串，如 INFO DEBUG ERROR
-	if cfg.LogFormat == "json" {
-		return zapcore.NewJSONEncoder(encoderConfig) // 以json格式写入
+func initLogger(cfg *Config, levels *ModuleLevels) (log *zap.Logger, s *sinks, err error) {
+	outputs, err := cfg.outputs()
+	if err != nil {
+		return nil, nil, err
 	}
-	return zapcore.NewConsoleEncoder(encoderConfig) // 以logfmt格式写入
+
+	var redactor *Redactor
+	if cfg.Redact != nil {
+		redactor, err = NewRedactor(*cfg.Redact)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+
+	s = &sinks{}
+	// the error returns set s to nil, close the sinks opened so far.
+	defer func(s *sinks) {
+		if err != nil {
+			_ = s.close()
+		}
+	}(s)
+	cores := make([]zapcore.Core, 0, len(outputs))
+	var audits []zapcore.Core
+	for _, out := range outputs {
+		core, err := newOutputCore(out, levels, s)
+		if err != nil {
+			return nil, nil, err
+		}
+		if redactor != nil {
+			core = NewRedactCore(core, redactor)
+		}
+		if out.Type == OutputAudit {
+			audits = append(audits, core)
+			continue
+		}
+		cores = append(cores, core)
+	}
+	if cfg.Hook != nil {
+		core, err := NewHookCore(*cfg.Hook)
+		if err != nil {
+			return nil, nil, err
+		}
+		hook := core.(*hookCore)
+		s.add(hook, nil)
+		s.addDropped(DroppedHook, func() uint64 { return hook.Stats().Dropped })
+		if redactor != nil {
+			core = NewRedactCore(core, redactor)
+		}
+		cores = append(cores, core)
+	}
+
+	var (
+		samplerHook func(zapcore.Entry, zapcore.SamplingDecision)
+		onSuppress  func(zapcore.Level)
+	)
+	if cfg.Metrics != nil {
+		// counted after module levels, sampling and rate limit.
+		cores = append(cores, &metricsCore{LevelEnabler: levels, metrics: cfg.Metrics})
+		s.metrics = cfg.Metrics
+		cfg.Metrics.addSinks(s)
+		samplerHook = cfg.Metrics.samplerHook
+		onSuppress = cfg.Metrics.suppressed
+	}
+
+	core := newModuleCore(zapcore.NewTee(cores...), levels)
+	if len(cfg.Sampling) > 0 {
+		core, err = newSamplingCore(core, samplerHook, cfg.Sampling...)
+		if err != nil {
+			return nil, nil, err
+		}
+	}
+	if len(cfg.RateLimit) > 0 {
+		limited, err := newRateLimitCore(core, onSuppress, cfg.RateLimit...)
+		if err != nil {
+			return nil, nil, err
+		}
+		// added last so the summaries are written before the outputs close.
+		s.add(limited, nil)
+		core = limited
+	}
+	if len(audits) > 0 {
+		// a dropped entry would leave a complete looking chain, the audit
+		// outputs get every entry of their levels.
+		core = zapcore.NewTee(append([]zapcore.Core{core}, audits...)...)
+	}
+	var zapOpts []zap.Option
+	if cfg.Caller {
+		zapOpts = append(zapOpts, zap.AddCaller(), zap.AddCallerSkip(cfg.CallerSkip))
+	}
+	if cfg.StacktraceLevel != "" {
+		level, err := parseLevel(cfg.StacktraceLevel)
+		if err != nil {
+			return nil, nil, err
+		}
+		zapOpts = append(zapOpts, zap.AddStacktrace(level))
+	}
+	l := zap.New(core, zapOpts...)
+
+	return l, s, nil
 }
 
-func getLogWriter(cfg *Config) (zapcore.WriteSyncer, error) {
-	err := fileutil.CreatePath(cfg.LogPath)
+// getEncoder 编码器(如何写入日志), encoding 为空时使用默认的时间 级别格式和字段名
+func getEncoder(format string, encoding *EncodingConfig) (zapcore.Encoder, error) {
+	encoderConfig, err := encoding.encoderConfig()
 	if err != nil {
 		return nil, err
 	}
-
-	lumberJackLogger := &lumberjack.Logger{
-		Filename:   filepath.Join(cfg.LogPath, cfg.LogFileName), // 日志文件路径
-		MaxSize:    cfg.LogFileMaxSize,                          // 单个日志文件最大多少 mb
-		MaxBackups: cfg.LogFileMaxBackups,                       // 日志备份数量
-		MaxAge:     cfg.LogMaxAge,                               // 日志最长保留时间
-		Compress:   cfg.LogCompress,                             // 是否压缩日志
+	switch format {
+	case "json":
+		return zapcore.NewJSONEncoder(encoderConfig), nil // 以json格式写入
+	case "logfmt":
+		return NewLogfmtEncoder(encoderConfig), nil // 以logfmt格式写入
+	case "pretty":
+		return NewPrettyEncoder(encoderConfig, false), nil // 以便于阅读的格式写入, 无颜色
 	}
+	return zapcore.NewConsoleEncoder(encoderConfig), nil // 以console格式写入
+}
 
-	if cfg.LogType == LogStdout {
+func getLogWriter(out Output, s *sinks) (zapcore.WriteSyncer, error) {
+	switch out.Type {
+	case OutputStdout:
 		return zapcore.AddSync(os.Stdout), nil
-	} else if cfg.LogType == LogFile {
-		return zapcore.AddSync(lumberJackLogger), nil
-	} else if cfg.LogType == LogStdoutAndFile {
-		return zapcore.NewMultiWriteSyncer(zapcore.AddSync(os.Stdout), zapcore.AddSync(lumberJackLogger)), nil
+	case OutputStderr:
+		return zapcore.AddSync(os.Stderr), nil
+	case OutputWriter:
+		if out.Writer == nil {
+			return nil, errors.New("writer output without writer")
+		}
+		return zapcore.AddSync(out.Writer), nil
+	case OutputFile:
+		err := fileutil.CreatePath(out.Path)
+		if err != nil {
+			return nil, err
+		}
+
+		compress := out.Compress != nil && *out.Compress
+		var publicKey []byte
+		if out.Encrypt != nil {
+			publicKey, err = out.Encrypt.publicKey()
+			if err != nil {
+				return nil, err
+			}
+		}
+
+		switch out.Rotate {
+		case "", RotateSize:
+			if publicKey != nil {
+				return nil, errors.New("encrypted file output must be rotated daily or hourly")
+			}
+			lumberJackLogger := &lumberjack.Logger{
+				Filename:   filepath.Join(out.Path, out.FileName), // 日志文件路径
+				MaxSize:    out.MaxSize,                           // 单个日志文件最大多少 mb
+				MaxBackups: out.MaxBackups,                        // 日志备份数量
+				MaxAge:     out.MaxAge,                            // 日志最长保留时间
+				Compress:   compress,                              // 是否压缩日志
+			}
+			s.add(lumberJackLogger, func() string { return lumberJackLogger.Filename })
+			return zapcore.AddSync(lumberJackLogger), nil
+		case RotateDaily, RotateHourly:
+			timeRotateWriter := &TimeRotateWriter{
+				Filename:   filepath.Join(out.Path, out.FileName), // 日志文件路径, 实际文件名会加上日期
+				Interval:   out.Rotate,                            // 分割周期
+				MaxSize:    out.MaxSize,                           // 单个日志文件最大多少 mb
+				MaxBackups: out.MaxBackups,                        // 日志备份数量
+				MaxAge:     out.MaxAge,                            // 日志最长保留时间
+				Compress:   compress,                              // 是否压缩日志
+				LocalTime:  true,                                  // 按本地日期命名
+				PublicKey:  publicKey,                             // 加密公钥
+			}
+			s.add(timeRotateWriter, timeRotateWriter.CurrentFile)
+			return timeRotateWriter, nil
+		}
+		return nil, fmt.Errorf("unknown rotate mode %q", out.Rotate)
 	}
-	return nil, err
+	return nil, fmt.Errorf("unknown output type %q", out.Type)
 }
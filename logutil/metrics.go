@@ -0,0 +1,262 @@
+package logutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// Dropped entry sources of the metrics.
+const (
+	DroppedAsync = "async" // 异步写入缓冲满时丢弃
+	DroppedHTTP  = "http"  // http 输出队列满且未落盘时丢弃
+	DroppedHook  = "hook"  // hook 队列满时丢弃
+)
+
+type metricKey struct {
+	level  zapcore.Level
+	logger string
+}
+
+// Metrics counts the entries by level and logger name, and the entries
+// dropped by sampling, rate limit, async writers, http outputs and hooks. One
+// Metrics may be shared by several loggers, the dropped entries of a logger
+// are removed when its outputs are closed, and its entries when it is shut
+// down by Registry.Shutdown.
+type Metrics struct {
+	mu      sync.RWMutex
+	entries map[metricKey]*atomic.Uint64
+	sinks   map[*sinks]struct{} // outputs counting dropped entries
+
+	sampled     [zapcore.FatalLevel - zapcore.DebugLevel + 1]atomic.Uint64
+	rateLimited [zapcore.FatalLevel - zapcore.DebugLevel + 1]atomic.Uint64
+}
+
+// MetricsEntry the number of entries of a level and logger.
+type MetricsEntry struct {
+	Level  zapcore.Level
+	Logger string
+	Count  uint64
+}
+
+// MetricsSnapshot the counters of the metrics.
+type MetricsSnapshot struct {
+	Entries     []MetricsEntry           // 按级别和 logger 统计的日志条数, 按级别和 logger 排序
+	Sampled     map[zapcore.Level]uint64 // 采样丢弃的条数
+	RateLimited map[zapcore.Level]uint64 // 限流丢弃的条数
+	Dropped     map[string]uint64        // 按来源统计的丢弃条数, 如 async http hook
+}
+
+// Count returns the number of entries at level, logger "" counts all loggers.
+func (s MetricsSnapshot) Count(level zapcore.Level, logger string) uint64 {
+	var n uint64
+	for _, e := range s.Entries {
+		if e.Level == level && (logger == "" || e.Logger == logger) {
+			n += e.Count
+		}
+	}
+	return n
+}
+
+// NewMetrics new metrics, pass it to the logger by WithMetrics.
+func NewMetrics() *Metrics {
+	return &Metrics{
+		entries: make(map[metricKey]*atomic.Uint64),
+		sinks:   make(map[*sinks]struct{}),
+	}
+}
+
+// NewMetricsCore returns core counting its entries to m.
+func NewMetricsCore(core zapcore.Core, m *Metrics) zapcore.Core {
+	return zapcore.NewTee(core, &metricsCore{LevelEnabler: core, metrics: m})
+}
+
+// Snapshot returns the current counters.
+func (m *Metrics) Snapshot() MetricsSnapshot {
+	s := MetricsSnapshot{
+		Sampled:     make(map[zapcore.Level]uint64),
+		RateLimited: make(map[zapcore.Level]uint64),
+		Dropped:     make(map[string]uint64),
+	}
+
+	m.mu.RLock()
+	for key, n := range m.entries {
+		s.Entries = append(s.Entries, MetricsEntry{Level: key.level, Logger: key.logger, Count: n.Load()})
+	}
+	for sinks := range m.sinks {
+		for source, n := range sinks.droppedCounts() {
+			s.Dropped[source] += n
+		}
+	}
+	m.mu.RUnlock()
+
+	sort.Slice(s.Entries, func(i, j int) bool {
+		if s.Entries[i].Level != s.Entries[j].Level {
+			return s.Entries[i].Level < s.Entries[j].Level
+		}
+		return s.Entries[i].Logger < s.Entries[j].Logger
+	})
+	for level := zapcore.DebugLevel; level <= zapcore.FatalLevel; level++ {
+		if n := m.sampled[level-zapcore.DebugLevel].Load(); n > 0 {
+			s.Sampled[level] = n
+		}
+		if n := m.rateLimited[level-zapcore.DebugLevel].Load(); n > 0 {
+			s.RateLimited[level] = n
+		}
+	}
+	return s
+}
+
+// Handler returns a http handler writing the metrics in the Prometheus text
+// format, e.g.
+//
+//	http.Handle("/metrics/log", metrics.Handler())
+func (m *Metrics) Handler() http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.Header().Set("Content-Type", "text/plain; version=0.0.4; charset=utf-8")
+		_, _ = w.Write([]byte(m.Snapshot().prometheus()))
+	})
+}
+
+// Report call fn with the snapshot every interval until ctx is done.
+func (m *Metrics) Report(ctx context.Context, interval time.Duration, fn func(MetricsSnapshot)) {
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		select {
+		case <-ctx.Done():
+			return
+		case <-ticker.C:
+			fn(m.Snapshot())
+		}
+	}
+}
+
+func (m *Metrics) count(ent zapcore.Entry) {
+	key := metricKey{level: ent.Level, logger: ent.LoggerName}
+	m.mu.RLock()
+	n, ok := m.entries[key]
+	m.mu.RUnlock()
+	if !ok {
+		m.mu.Lock()
+		n, ok = m.entries[key]
+		if !ok {
+			n = &atomic.Uint64{}
+			m.entries[key] = n
+		}
+		m.mu.Unlock()
+	}
+	n.Add(1)
+}
+
+// samplerHook count the entries dropped by the sampler.
+func (m *Metrics) samplerHook(ent zapcore.Entry, dec zapcore.SamplingDecision) {
+	if dec&zapcore.LogDropped != 0 && validLevel(ent.Level) {
+		m.sampled[ent.Level-zapcore.DebugLevel].Add(1)
+	}
+}
+
+// suppressed count the entries suppressed by the rate limiter.
+func (m *Metrics) suppressed(level zapcore.Level) {
+	if validLevel(level) {
+		m.rateLimited[level-zapcore.DebugLevel].Add(1)
+	}
+}
+
+// addSinks count the dropped entries of the outputs s.
+func (m *Metrics) addSinks(s *sinks) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.sinks[s] = struct{}{}
+}
+
+// removeSinks stop counting the dropped entries of the outputs s.
+func (m *Metrics) removeSinks(s *sinks) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	delete(m.sinks, s)
+}
+
+// removeLogger remove the entries of the logger name and its named children,
+// only the unnamed entries for the root logger "".
+func (m *Metrics) removeLogger(name string) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	for key := range m.entries {
+		if key.logger == name || (name != "" && strings.HasPrefix(key.logger, name+".")) {
+			delete(m.entries, key)
+		}
+	}
+}
+
+func validLevel(level zapcore.Level) bool {
+	return level >= zapcore.DebugLevel && level <= zapcore.FatalLevel
+}
+
+// prometheus returns the snapshot in the Prometheus text format.
+func (s MetricsSnapshot) prometheus() string {
+	var b strings.Builder
+	b.WriteString("# HELP log_entries_total Number of log entries by level and logger.\n")
+	b.WriteString("# TYPE log_entries_total counter\n")
+	for _, e := range s.Entries {
+		fmt.Fprintf(&b, "log_entries_total{level=\"%s\",logger=\"%s\"} %d\n", e.Level, promEscaper.Replace(e.Logger), e.Count)
+	}
+
+	writeLevels := func(name, help string, counts map[zapcore.Level]uint64) {
+		fmt.Fprintf(&b, "# HELP %s %s\n# TYPE %s counter\n", name, help, name)
+		for level := zapcore.DebugLevel; level <= zapcore.FatalLevel; level++ {
+			if n, ok := counts[level]; ok {
+				fmt.Fprintf(&b, "%s{level=\"%s\"} %d\n", name, level, n)
+			}
+		}
+	}
+	writeLevels("log_sampled_total", "Number of log entries dropped by sampling.", s.Sampled)
+	writeLevels("log_rate_limited_total", "Number of log entries suppressed by rate limit.", s.RateLimited)
+
+	b.WriteString("# HELP log_dropped_total Number of log entries dropped by source.\n")
+	b.WriteString("# TYPE log_dropped_total counter\n")
+	sources := make([]string, 0, len(s.Dropped))
+	for source := range s.Dropped {
+		sources = append(sources, source)
+	}
+	sort.Strings(sources)
+	for _, source := range sources {
+		fmt.Fprintf(&b, "log_dropped_total{source=\"%s\"} %d\n", promEscaper.Replace(source), s.Dropped[source])
+	}
+	return b.String()
+}
+
+var promEscaper = strings.NewReplacer(`\`, `\\`, `"`, `\"`, "\n", `\n`)
+
+// metricsCore count the entries written to it.
+type metricsCore struct {
+	zapcore.LevelEnabler
+	metrics *Metrics
+}
+
+func (c *metricsCore) With([]zapcore.Field) zapcore.Core {
+	return c
+}
+
+func (c *metricsCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *metricsCore) Write(ent zapcore.Entry, _ []zapcore.Field) error {
+	c.metrics.count(ent)
+	return nil
+}
+
+func (c *metricsCore) Sync() error {
+	return nil
+}
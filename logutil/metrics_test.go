@@ -0,0 +1,135 @@
+package logutil
+
+import (
+	"context"
+	"io"
+	"net/http/httptest"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+func TestMetrics(t *testing.T) {
+	metrics := NewMetrics()
+	l, err := New(&Config{
+		LogLevel:        "info",
+		LogFormat:       "json",
+		LogModuleLevels: "db=error",
+		LogPath:         t.TempDir(),
+		LogFileName:     "app.log",
+		Outputs: []Output{{
+			Type:  OutputFile,
+			Async: &AsyncConfig{BufferSize: 1, Overflow: OverflowDropNewest, FlushInterval: time.Hour},
+		}},
+		Sampling:  []SamplingConfig{{Level: "debug", Initial: 1}, {Level: "info", Initial: 2, Thereafter: 0, Tick: time.Hour}},
+		RateLimit: []RateLimitConfig{{Level: "warn", Limit: 1, Interval: time.Hour}},
+	}, WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for i := 0; i < 5; i++ {
+		l.Info("sampled")
+	}
+	for i := 0; i < 3; i++ {
+		l.Warn("limited")
+	}
+	db := l.Named("db")
+	db.Info("below module level")
+	db.Error("query failed")
+	db.Error("query failed")
+	l.Debug("below level")
+
+	s := metrics.Snapshot()
+	if s.Count(zapcore.InfoLevel, "") != 2 || s.Count(zapcore.WarnLevel, "") != 1 || s.Count(zapcore.ErrorLevel, "db") != 2 {
+		t.Fatalf("unexpected entries %+v", s.Entries)
+	}
+	if s.Count(zapcore.InfoLevel, "db") != 0 || s.Count(zapcore.DebugLevel, "") != 0 {
+		t.Fatalf("filtered entries counted %+v", s.Entries)
+	}
+	if s.Sampled[zapcore.InfoLevel] != 3 || s.RateLimited[zapcore.WarnLevel] != 2 {
+		t.Fatalf("unexpected sampled %v rate limited %v", s.Sampled, s.RateLimited)
+	}
+	if s.Dropped[DroppedAsync] == 0 {
+		t.Fatalf("async drops not counted %v", s.Dropped)
+	}
+
+	rec := httptest.NewRecorder()
+	metrics.Handler().ServeHTTP(rec, httptest.NewRequest("GET", "/metrics", nil))
+	body, _ := io.ReadAll(rec.Body)
+	for _, line := range []string{
+		"# TYPE log_entries_total counter",
+		`log_entries_total{level="error",logger="db"} 2`,
+		`log_entries_total{level="info",logger=""} 2`,
+		`log_sampled_total{level="info"} 3`,
+		`log_rate_limited_total{level="warn"} 2`,
+		`log_dropped_total{source="async"} `,
+	} {
+		if !strings.Contains(string(body), line) {
+			t.Fatalf("%q not found in\n%s", line, body)
+		}
+	}
+
+	ctx, cancel := context.WithCancel(context.Background())
+	reported := make(chan MetricsSnapshot, 1)
+	go metrics.Report(ctx, 10*time.Millisecond, func(s MetricsSnapshot) {
+		select {
+		case reported <- s:
+		default:
+		}
+	})
+	select {
+	case s := <-reported:
+		if s.Count(zapcore.ErrorLevel, "") != 2 {
+			t.Fatalf("unexpected reported entries %+v", s.Entries)
+		}
+	case <-time.After(5 * time.Second):
+		t.Fatal("metrics not reported")
+	}
+	cancel()
+	_ = lastSinks.Load().close()
+}
+
+func TestMetricsRegistryShutdown(t *testing.T) {
+	metrics := NewMetrics()
+	dir := t.TempDir()
+	r := NewRegistry()
+	app, err := r.Register("app", &Config{
+		LogLevel:    "info",
+		LogPath:     dir,
+		LogFileName: "app.log",
+		Outputs: []Output{{
+			Type:  OutputFile,
+			Async: &AsyncConfig{BufferSize: 1, Overflow: OverflowDropNewest, FlushInterval: time.Hour},
+		}},
+	}, WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for i := 0; i < 5; i++ {
+		app.Info("app")
+		app.Named("db").Info("db")
+	}
+	// a logger sharing the metrics outside the registry.
+	other, err := New(&Config{LogLevel: "info", Outputs: []Output{{Type: OutputWriter, Writer: io.Discard}}}, WithMetrics(metrics))
+	if err != nil {
+		t.Fatal(err)
+	}
+	other.Named("application").Info("other")
+
+	s := metrics.Snapshot()
+	if s.Count(zapcore.InfoLevel, "app") != 5 || s.Count(zapcore.InfoLevel, "app.db") != 5 || s.Dropped[DroppedAsync] == 0 {
+		t.Fatalf("unexpected snapshot %+v", s)
+	}
+
+	err = r.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	s = metrics.Snapshot()
+	if len(s.Entries) != 1 || s.Entries[0].Logger != "application" || s.Dropped[DroppedAsync] != 0 {
+		t.Fatalf("series of the shut down logger kept %+v", s)
+	}
+}
@@ -0,0 +1,240 @@
+package logutil
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"net/http"
+	"sort"
+	"strings"
+	"sync"
+	"sync/atomic"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// ModuleLevels per module log levels. Module loggers are created with
+// logger.Named("db") and logger.Named("db").Named("pool"), the level of a
+// module is looked up by its dotted name and then its parents, so "db"
+// applies to "db.pool" too. Modules without a level use the root level.
+type ModuleLevels struct {
+	root    zap.AtomicLevel
+	mu      sync.Mutex
+	modules atomic.Pointer[map[string]zapcore.Level]
+}
+
+// NewModuleLevels new module levels with the root level.
+func NewModuleLevels(root zap.AtomicLevel) *ModuleLevels {
+	m := &ModuleLevels{root: root}
+	m.modules.Store(&map[string]zapcore.Level{})
+	return m
+}
+
+// Root returns the root level.
+func (m *ModuleLevels) Root() zap.AtomicLevel {
+	return m.root
+}
+
+// SetLevel set the level of module name.
+func (m *ModuleLevels) SetLevel(name string, level zapcore.Level) {
+	m.update(func(modules map[string]zapcore.Level) {
+		modules[name] = level
+	})
+}
+
+// UnsetLevel remove the level of module name, it uses its parent level again.
+func (m *ModuleLevels) UnsetLevel(name string) {
+	m.update(func(modules map[string]zapcore.Level) {
+		delete(modules, name)
+	})
+}
+
+// Set apply levels spec like "db=debug,http=warn". A bare level changes the
+// root level and "name=" removes the level of the module.
+func (m *ModuleLevels) Set(spec string) error {
+	root, modules, err := parseModuleLevels(spec)
+	if err != nil {
+		return err
+	}
+	if root != nil {
+		m.root.SetLevel(*root)
+	}
+	m.update(func(current map[string]zapcore.Level) {
+		for name, level := range modules {
+			if level == nil {
+				delete(current, name)
+				continue
+			}
+			current[name] = *level
+		}
+	})
+	return nil
+}
+
+// Level returns the level of module name.
+func (m *ModuleLevels) Level(name string) zapcore.Level {
+	modules := *m.modules.Load()
+	if len(modules) == 0 {
+		return m.root.Level()
+	}
+	for name != "" {
+		if level, ok := modules[name]; ok {
+			return level
+		}
+		i := strings.LastIndexByte(name, '.')
+		if i < 0 {
+			break
+		}
+		name = name[:i]
+	}
+	return m.root.Level()
+}
+
+// Enabled reports whether the level is enabled by the root or any module.
+func (m *ModuleLevels) Enabled(level zapcore.Level) bool {
+	if m.root.Enabled(level) {
+		return true
+	}
+	for _, l := range *m.modules.Load() {
+		if l.Enabled(level) {
+			return true
+		}
+	}
+	return false
+}
+
+// Modules returns a copy of the module levels.
+func (m *ModuleLevels) Modules() map[string]zapcore.Level {
+	modules := make(map[string]zapcore.Level)
+	for name, level := range *m.modules.Load() {
+		modules[name] = level
+	}
+	return modules
+}
+
+// String returns the levels spec, root level first.
+func (m *ModuleLevels) String() string {
+	modules := *m.modules.Load()
+	names := make([]string, 0, len(modules))
+	for name := range modules {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	items := []string{m.root.Level().String()}
+	for _, name := range names {
+		items = append(items, name+"="+modules[name].String())
+	}
+	return strings.Join(items, ",")
+}
+
+type moduleLevelsPayload struct {
+	Level   string            `json:"level"`
+	Modules map[string]string `json:"modules"`
+}
+
+// ServeHTTP GET returns the root and module levels as json, PUT applies the
+// levels spec in the request body, e.g.
+//
+//	curl -X PUT -d 'db=debug,http=warn' http://localhost:8080/log/modules
+func (m *ModuleLevels) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.Method {
+	case http.MethodGet:
+	case http.MethodPut:
+		body, err := io.ReadAll(r.Body)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		err = m.Set(string(body))
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+	default:
+		http.Error(w, "only GET and PUT are supported", http.StatusMethodNotAllowed)
+		return
+	}
+
+	payload := moduleLevelsPayload{
+		Level:   m.root.Level().String(),
+		Modules: map[string]string{},
+	}
+	for name, level := range m.Modules() {
+		payload.Modules[name] = level.String()
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(payload)
+}
+
+func (m *ModuleLevels) update(fn func(modules map[string]zapcore.Level)) {
+	m.mu.Lock()
+	defer m.mu.Unlock()
+
+	modules := m.Modules()
+	fn(modules)
+	m.modules.Store(&modules)
+}
+
+func parseModuleLevels(spec string) (*zapcore.Level, map[string]*zapcore.Level, error) {
+	var root *zapcore.Level
+	modules := make(map[string]*zapcore.Level)
+	for _, item := range strings.Split(spec, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		name, levelName, found := strings.Cut(item, "=")
+		if !found {
+			level, err := parseLevel(item)
+			if err != nil {
+				return nil, nil, err
+			}
+			root = &level
+			continue
+		}
+
+		name = strings.TrimSpace(name)
+		if name == "" {
+			return nil, nil, fmt.Errorf("empty module name in %q", item)
+		}
+		levelName = strings.TrimSpace(levelName)
+		if levelName == "" {
+			modules[name] = nil
+			continue
+		}
+		level, err := parseLevel(levelName)
+		if err != nil {
+			return nil, nil, fmt.Errorf("module %s: %w", name, err)
+		}
+		modules[name] = &level
+	}
+	return root, modules, nil
+}
+
+// moduleCore filter entries by the level of their logger name.
+type moduleCore struct {
+	zapcore.Core
+	levels *ModuleLevels
+}
+
+func newModuleCore(core zapcore.Core, levels *ModuleLevels) zapcore.Core {
+	return &moduleCore{Core: core, levels: levels}
+}
+
+func (c *moduleCore) Enabled(level zapcore.Level) bool {
+	return c.levels.Enabled(level)
+}
+
+func (c *moduleCore) With(fields []zapcore.Field) zapcore.Core {
+	return &moduleCore{Core: c.Core.With(fields), levels: c.levels}
+}
+
+func (c *moduleCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if !c.levels.Level(ent.LoggerName).Enabled(ent.Level) {
+		return ce
+	}
+	return c.Core.Check(ent, ce)
+}
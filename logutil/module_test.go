@@ -0,0 +1,109 @@
+package logutil
+
+import (
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestModuleLevels(t *testing.T) {
+	levels := NewModuleLevels(zap.NewAtomicLevelAt(zapcore.InfoLevel))
+	err := levels.Set("warn,db=debug,db.pool=error")
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for name, want := range map[string]zapcore.Level{
+		"":             zapcore.WarnLevel,
+		"http":         zapcore.WarnLevel,
+		"db":           zapcore.DebugLevel,
+		"db.query":     zapcore.DebugLevel,
+		"db.pool":      zapcore.ErrorLevel,
+		"db.pool.conn": zapcore.ErrorLevel,
+		"dbx":          zapcore.WarnLevel,
+	} {
+		if got := levels.Level(name); got != want {
+			t.Errorf("level of %q = %s, want %s", name, got, want)
+		}
+	}
+
+	core, logs := observer.New(zap.DebugLevel)
+	l := zap.New(newModuleCore(core, levels))
+	l.Info("root info")
+	l.Named("db").Debug("db debug")
+	l.Named("db").Named("pool").Warn("pool warn")
+	l.Named("db").Named("pool").Error("pool error")
+	if got := messages(logs); got != "db debug,pool error" {
+		t.Fatalf("got %q", got)
+	}
+
+	// runtime changes.
+	levels.UnsetLevel("db.pool")
+	levels.SetLevel("http", zapcore.ErrorLevel)
+	err = levels.Set("db=")
+	if err != nil {
+		t.Fatal(err)
+	}
+	if levels.Level("db.pool") != zapcore.WarnLevel || levels.Level("http.client") != zapcore.ErrorLevel {
+		t.Fatalf("unexpected levels %s", levels)
+	}
+	if levels.String() != "warn,http=error" {
+		t.Fatalf("unexpected spec %q", levels.String())
+	}
+
+	for _, spec := range []string{"db=verbose", "=debug", "loud"} {
+		if err := levels.Set(spec); err == nil {
+			t.Errorf("expected error for %q", spec)
+		}
+	}
+	if levels.String() != "warn,http=error" {
+		t.Fatalf("invalid spec changed levels %q", levels.String())
+	}
+}
+
+func TestModuleLevelsHandler(t *testing.T) {
+	levels := NewModuleLevels(zap.NewAtomicLevelAt(zapcore.InfoLevel))
+
+	rec := httptest.NewRecorder()
+	levels.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/modules", strings.NewReader("db=debug,http=warn")))
+	if rec.Code != http.StatusOK {
+		t.Fatalf("unexpected put %d %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	levels.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/log/modules", nil))
+	var payload moduleLevelsPayload
+	err := json.Unmarshal(rec.Body.Bytes(), &payload)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if payload.Level != "info" || payload.Modules["db"] != "debug" || payload.Modules["http"] != "warn" {
+		t.Fatalf("unexpected payload %+v", payload)
+	}
+
+	rec = httptest.NewRecorder()
+	levels.ServeHTTP(rec, httptest.NewRequest(http.MethodPut, "/log/modules", strings.NewReader("db=verbose")))
+	if rec.Code != http.StatusBadRequest || levels.Level("db") != zapcore.DebugLevel {
+		t.Fatalf("unexpected invalid put %d %s", rec.Code, rec.Body)
+	}
+
+	rec = httptest.NewRecorder()
+	levels.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, "/log/modules", nil))
+	if rec.Code != http.StatusMethodNotAllowed {
+		t.Fatalf("unexpected post %d", rec.Code)
+	}
+}
+
+func messages(logs *observer.ObservedLogs) string {
+	var msgs []string
+	for _, ent := range logs.All() {
+		msgs = append(msgs, ent.Message)
+	}
+	return strings.Join(msgs, ",")
+}
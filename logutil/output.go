@@ -0,0 +1,189 @@
+package logutil
+
+import (
+	"errors"
+	"fmt"
+	"io"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// output types.
+const (
+	OutputStdout   = "stdout"
+	OutputStderr   = "stderr"
+	OutputFile     = "file"
+	OutputWriter   = "writer"
+	OutputSyslog   = "syslog"
+	OutputJournald = "journald"
+	OutputHTTP     = "http"
+	OutputAudit    = "audit"
+)
+
+// Output log output, empty fields use the values of Config.
+type Output struct {
+	Type       string          `json:"type" yaml:"type"`               // 输出类型 stdout stderr file writer syslog journald http audit
+	Level      string          `json:"level" yaml:"level"`             // 输出的最低日志级别, 为空时不限制
+	Format     string          `json:"format" yaml:"format"`           // 输出日志格式 console, logfmt, json, pretty
+	Path       string          `json:"path" yaml:"path"`               // 输出日志文件路径
+	FileName   string          `json:"file_name" yaml:"file_name"`     // 输出日志文件名称
+	MaxSize    int             `json:"max_size" yaml:"max_size"`       // 【日志分割】单个日志文件最多存储量 单位(mb)
+	MaxBackups int             `json:"max_backups" yaml:"max_backups"` // 【日志分割】日志备份文件最多数量
+	MaxAge     int             `json:"max_age" yaml:"max_age"`         // 日志保留时间，单位: 天 (day)
+	Compress   *bool           `json:"compress" yaml:"compress"`       // 是否压缩日志, 为空时使用 Config 的配置
+	Rotate     string          `json:"rotate" yaml:"rotate"`           // 日志分割方式 size daily hourly
+	Writer     io.Writer       `json:"-" yaml:"-"`                     // writer 类型的输出
+	Async      *AsyncConfig    `json:"async" yaml:"async"`             // 异步写入配置, 为空时同步写入
+	Syslog     *SyslogConfig   `json:"syslog" yaml:"syslog"`           // syslog 类型的输出配置
+	Journald   *JournaldConfig `json:"journald" yaml:"journald"`       // journald 类型的输出配置
+	HTTP       *HTTPConfig     `json:"http" yaml:"http"`               // http 类型的输出配置
+	Audit      *AuditConfig    `json:"audit" yaml:"audit"`             // audit 类型的输出配置, 写入文件, 不支持异步, 不受模块级别 采样和限流影响
+	Encrypt    *EncryptConfig  `json:"encrypt" yaml:"encrypt"`         // file 类型的加密配置, 为空时不加密
+	Encoding   *EncodingConfig `json:"encoding" yaml:"encoding"`       // 编码配置, 为空时使用 Config 的编码配置
+}
+
+// outputs returns Outputs, or the outputs of LogType if Outputs is empty.
+func (cfg *Config) outputs() ([]Output, error) {
+	var outputs []Output
+	if len(cfg.Outputs) > 0 {
+		outputs = append(outputs, cfg.Outputs...)
+	} else {
+		switch cfg.LogType {
+		case LogStdout:
+			outputs = []Output{{Type: OutputStdout}}
+		case LogFile:
+			outputs = []Output{{Type: OutputFile}}
+		case LogStdoutAndFile:
+			outputs = []Output{{Type: OutputStdout}, {Type: OutputFile}}
+		default:
+			return nil, fmt.Errorf("unknown log type %d", cfg.LogType)
+		}
+	}
+
+	for i := range outputs {
+		out := &outputs[i]
+		if out.Format == "" {
+			out.Format = cfg.LogFormat
+		}
+		if out.Path == "" {
+			out.Path = cfg.LogPath
+		}
+		if out.FileName == "" {
+			out.FileName = cfg.LogFileName
+		}
+		if out.MaxSize == 0 {
+			out.MaxSize = cfg.LogFileMaxSize
+		}
+		if out.MaxBackups == 0 {
+			out.MaxBackups = cfg.LogFileMaxBackups
+		}
+		if out.MaxAge == 0 {
+			out.MaxAge = cfg.LogMaxAge
+		}
+		if out.Compress == nil {
+			compress := cfg.LogCompress
+			out.Compress = &compress
+		}
+		if out.Rotate == "" {
+			out.Rotate = cfg.LogRotate
+		}
+		if out.Async == nil {
+			out.Async = cfg.Async
+		}
+		if out.Encoding == nil {
+			out.Encoding = cfg.Encoding
+		}
+	}
+	return outputs, nil
+}
+
+func newOutputCore(out Output, levels *ModuleLevels, s *sinks) (zapcore.Core, error) {
+	threshold := zapcore.DebugLevel
+	if out.Level != "" {
+		var err error
+		threshold, err = parseLevel(out.Level)
+		if err != nil {
+			return nil, err
+		}
+	}
+	enabler := zap.LevelEnablerFunc(func(level zapcore.Level) bool {
+		return level >= threshold && levels.Enabled(level)
+	})
+
+	// syslog, journald, http and audit encode entries by their protocols.
+	var core zapcore.Core
+	var err error
+	switch out.Type {
+	case OutputSyslog:
+		var cfg SyslogConfig
+		if out.Syslog != nil {
+			cfg = *out.Syslog
+		}
+		core, err = NewSyslogCore(cfg, enabler)
+	case OutputJournald:
+		var cfg JournaldConfig
+		if out.Journald != nil {
+			cfg = *out.Journald
+		}
+		core, err = NewJournaldCore(cfg, enabler)
+	case OutputHTTP:
+		if out.HTTP == nil {
+			return nil, errors.New("http output without http config")
+		}
+		// loki lines may be any format, the others are json documents.
+		format := "json"
+		if out.HTTP.Format == HTTPFormatLoki {
+			format = out.Format
+		}
+		encoder, err := getEncoder(format, out.Encoding)
+		if err != nil {
+			return nil, err
+		}
+		shipper, err := NewHTTPShipper(*out.HTTP)
+		if err != nil {
+			return nil, err
+		}
+		core = NewHTTPCore(shipper, encoder, enabler)
+		s.addDropped(DroppedHTTP, func() uint64 { return shipper.Stats().Dropped })
+	case OutputAudit:
+		// audit entries are json chained to the file, the file is closed by
+		// sinks. The core is teed in after module levels, sampling and rate
+		// limit, it only follows the output and root levels.
+		root := levels.Root()
+		return newAuditOutputCore(out, zap.LevelEnablerFunc(func(level zapcore.Level) bool {
+			return level >= threshold && root.Enabled(level)
+		}), s)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if core != nil {
+		if closer, ok := core.(io.Closer); ok {
+			s.add(closer, nil)
+		}
+		return core, nil
+	}
+
+	writeSyncer, err := getLogWriter(out, s)
+	if err != nil {
+		return nil, err
+	}
+	if out.Async != nil {
+		asyncWriter, err := NewAsyncWriteSyncer(writeSyncer, *out.Async)
+		if err != nil {
+			return nil, err
+		}
+		s.add(asyncWriter, nil)
+		s.addDropped(DroppedAsync, asyncWriter.Dropped)
+		writeSyncer = asyncWriter
+	}
+	encoder, err := getEncoder(out.Format, out.Encoding)
+	if err != nil {
+		return nil, err
+	}
+	if out.Format == "pretty" && colorEnabled(outputWriter(out)) {
+		encoder = NewPrettyEncoder(*encoder.(*prettyEncoder).EncoderConfig, true)
+	}
+	return zapcore.NewCore(encoder, writeSyncer, enabler), nil
+}
@@ -0,0 +1,67 @@
+package logutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"strings"
+	"testing"
+)
+
+func TestOutputs(t *testing.T) {
+	var warnings, all bytes.Buffer
+	l, err := New(&Config{
+		LogLevel:  "debug",
+		LogFormat: "json",
+		Outputs: []Output{
+			{Type: OutputWriter, Writer: &warnings, Level: "warn"},
+			{Type: OutputWriter, Writer: &all, Format: "logfmt"},
+		},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Debug("debug entry")
+	l.Info("info entry")
+	l.Warn("warn entry")
+	l.Error("error entry")
+	_ = l.Sync()
+
+	// the warn output gets json from Config.LogFormat.
+	lines := strings.Split(strings.TrimSpace(warnings.String()), "\n")
+	if len(lines) != 2 {
+		t.Fatalf("warn output got %q", lines)
+	}
+	for i, want := range []string{"warn entry", "error entry"} {
+		var fields map[string]interface{}
+		err := json.Unmarshal([]byte(lines[i]), &fields)
+		if err != nil {
+			t.Fatalf("warn output line %q: %v", lines[i], err)
+		}
+		if fields["msg"] != want {
+			t.Fatalf("warn output line %d msg %v, want %s", i, fields["msg"], want)
+		}
+	}
+
+	// the other output gets every level as logfmt.
+	lines = strings.Split(strings.TrimSpace(all.String()), "\n")
+	if len(lines) != 4 {
+		t.Fatalf("logfmt output got %q", lines)
+	}
+	for i, want := range []string{"level=DEBUG msg=\"debug entry\"", "level=INFO", "level=WARN", "level=ERROR"} {
+		if !strings.Contains(lines[i], want) || strings.HasPrefix(lines[i], "{") {
+			t.Fatalf("logfmt output line %q, want %s", lines[i], want)
+		}
+	}
+}
+
+func TestOutputsCompress(t *testing.T) {
+	off := false
+	cfg := &Config{LogCompress: true, Outputs: []Output{{Type: OutputFile}, {Type: OutputFile, Compress: &off}}}
+	outputs, err := cfg.outputs()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !*outputs[0].Compress || *outputs[1].Compress {
+		t.Fatalf("unexpected compress %v %v", *outputs[0].Compress, *outputs[1].Compress)
+	}
+}
@@ -0,0 +1,291 @@
+package logutil
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	prettyIndent      = "    "
+	prettyMaxColWidth = 32
+)
+
+var prettyPool = buffer.NewPool()
+
+// ansi colors of the pretty encoder.
+const (
+	colorReset   = "\x1b[0m"
+	colorRed     = "\x1b[31m"
+	colorBoldRed = "\x1b[1;31m"
+	colorYellow  = "\x1b[33m"
+	colorBlue    = "\x1b[34m"
+	colorMagenta = "\x1b[35m"
+	colorCyan    = "\x1b[36m"
+	colorGray    = "\x1b[90m"
+)
+
+func levelColor(level zapcore.Level) string {
+	switch level {
+	case zapcore.DebugLevel:
+		return colorMagenta
+	case zapcore.InfoLevel:
+		return colorCyan
+	case zapcore.WarnLevel:
+		return colorYellow
+	case zapcore.ErrorLevel:
+		return colorRed
+	}
+	return colorBoldRed
+}
+
+// prettyWidths the logger and caller column widths, shared by the clones of
+// an encoder so the columns stay aligned.
+type prettyWidths struct {
+	logger atomic.Int64
+	caller atomic.Int64
+}
+
+// prettyEncoder encode entries for reading in a terminal, e.g.
+//
+//	+1.204s INFO  db      store/user.go:42  user created
+//	    user={
+//	      "id": 42
+//	    }
+//
+// The time is relative to the creation of the encoder, fields are sorted by
+// key, nested objects and arrays are printed as indented json.
+type prettyEncoder struct {
+	*zapcore.EncoderConfig
+	*zapcore.MapObjectEncoder
+	namespaces []string
+	color      bool
+	start      time.Time
+	widths     *prettyWidths
+}
+
+// NewPrettyEncoder new pretty encoder, levels, keys and callers are colored
+// when color is true.
+func NewPrettyEncoder(cfg zapcore.EncoderConfig, color bool) zapcore.Encoder {
+	return &prettyEncoder{
+		EncoderConfig:    &cfg,
+		MapObjectEncoder: zapcore.NewMapObjectEncoder(),
+		color:            color,
+		start:            time.Now(),
+		widths:           &prettyWidths{},
+	}
+}
+
+// colorEnabled reports whether colors should be written to w, only terminals
+// get colors and NO_COLOR or TERM=dumb disable them.
+func colorEnabled(w io.Writer) bool {
+	if os.Getenv("NO_COLOR") != "" || os.Getenv("TERM") == "dumb" {
+		return false
+	}
+	f, ok := w.(*os.File)
+	if !ok {
+		return false
+	}
+	info, err := f.Stat()
+	return err == nil && info.Mode()&os.ModeCharDevice != 0
+}
+
+// outputWriter returns the writer of stdout, stderr and writer outputs.
+func outputWriter(out Output) io.Writer {
+	switch out.Type {
+	case OutputStdout:
+		return os.Stdout
+	case OutputStderr:
+		return os.Stderr
+	case OutputWriter:
+		return out.Writer
+	}
+	return nil
+}
+
+func (enc *prettyEncoder) OpenNamespace(key string) {
+	enc.namespaces = append(enc.namespaces[:len(enc.namespaces):len(enc.namespaces)], key)
+	enc.MapObjectEncoder.OpenNamespace(key)
+}
+
+func (enc *prettyEncoder) Clone() zapcore.Encoder {
+	return enc.clone()
+}
+
+// clone copy the fields, the namespaces are opened again so the new fields
+// are added to the innermost one.
+func (enc *prettyEncoder) clone() *prettyEncoder {
+	m := zapcore.NewMapObjectEncoder()
+	src := enc.MapObjectEncoder.Fields
+	for i := 0; ; i++ {
+		next := ""
+		if i < len(enc.namespaces) {
+			next = enc.namespaces[i]
+		}
+		for key, value := range src {
+			if i < len(enc.namespaces) && key == next {
+				continue
+			}
+			_ = m.AddReflected(key, value)
+		}
+		if i == len(enc.namespaces) {
+			break
+		}
+		m.OpenNamespace(next)
+		src, _ = src[next].(map[string]interface{})
+	}
+	return &prettyEncoder{
+		EncoderConfig:    enc.EncoderConfig,
+		MapObjectEncoder: m,
+		namespaces:       enc.namespaces,
+		color:            enc.color,
+		start:            enc.start,
+		widths:           enc.widths,
+	}
+}
+
+func (enc *prettyEncoder) EncodeEntry(ent zapcore.Entry, fields []zapcore.Field) (*buffer.Buffer, error) {
+	final := enc.clone()
+	for _, f := range fields {
+		f.AddTo(final)
+	}
+
+	buf := prettyPool.Get()
+	if enc.TimeKey != "" {
+		elapsed := ent.Time.Sub(enc.start)
+		if elapsed < 0 {
+			elapsed = 0
+		}
+		enc.colored(buf, colorGray, fmt.Sprintf("%9s", fmt.Sprintf("+%.3fs", elapsed.Seconds())))
+		buf.AppendByte(' ')
+	}
+	if enc.LevelKey != "" {
+		enc.colored(buf, levelColor(ent.Level), fmt.Sprintf("%-5s", ent.Level.CapitalString()))
+		buf.AppendByte(' ')
+	}
+	if enc.NameKey != "" {
+		if width := growWidth(&enc.widths.logger, len(ent.LoggerName)); width > 0 {
+			enc.colored(buf, colorBlue, fmt.Sprintf("%-*s", width, ent.LoggerName))
+			buf.AppendByte(' ')
+		}
+	}
+	if enc.CallerKey != "" && ent.Caller.Defined {
+		caller := ent.Caller.TrimmedPath()
+		width := growWidth(&enc.widths.caller, len(caller))
+		enc.colored(buf, colorGray, fmt.Sprintf("%-*s", width, caller))
+		buf.AppendByte(' ')
+	}
+	if enc.MessageKey != "" {
+		buf.AppendString(ent.Message)
+	}
+
+	enc.appendFields(buf, final.MapObjectEncoder.Fields)
+	if enc.StacktraceKey != "" && ent.Stack != "" {
+		for _, line := range strings.Split(strings.TrimRight(ent.Stack, "\n"), "\n") {
+			buf.AppendByte('\n')
+			buf.AppendString(prettyIndent)
+			enc.colored(buf, colorGray, line)
+		}
+	}
+
+	lineEnding := enc.LineEnding
+	if lineEnding == "" {
+		lineEnding = zapcore.DefaultLineEnding
+	}
+	buf.AppendString(lineEnding)
+	return buf, nil
+}
+
+// appendFields append the fields one per line, sorted by key.
+func (enc *prettyEncoder) appendFields(buf *buffer.Buffer, fields map[string]interface{}) {
+	keys := make([]string, 0, len(fields))
+	for key := range fields {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		buf.AppendByte('\n')
+		buf.AppendString(prettyIndent)
+		enc.colored(buf, colorCyan, key)
+		buf.AppendByte('=')
+		buf.AppendString(prettyValue(fields[key]))
+	}
+}
+
+// prettyValue returns the value as text, multi-line values are indented
+// under their key.
+func prettyValue(value interface{}) string {
+	var s string
+	switch v := value.(type) {
+	case string:
+		if strings.Contains(v, "\n") {
+			// multi-line text starts on its own line.
+			indent := "\n" + prettyIndent + "  "
+			return indent + strings.ReplaceAll(strings.TrimRight(v, "\n"), "\n", indent)
+		}
+		s = v
+		if needsQuote(s) {
+			s = strconv.Quote(s)
+		}
+	case []byte:
+		s = base64.StdEncoding.EncodeToString(v)
+	case time.Time:
+		s = v.Format(time.RFC3339Nano)
+	case time.Duration:
+		s = v.String()
+	case error:
+		s = v.Error()
+	case fmt.Stringer:
+		s = v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr,
+		float32, float64, complex64, complex128:
+		s = fmt.Sprint(v)
+	default:
+		data, err := json.MarshalIndent(v, "", "  ")
+		if err != nil {
+			s = fmt.Sprintf("%+v", v)
+		} else {
+			s = string(data)
+		}
+	}
+	if !strings.Contains(s, "\n") {
+		return s
+	}
+	return strings.ReplaceAll(s, "\n", "\n"+prettyIndent)
+}
+
+func (enc *prettyEncoder) colored(buf *buffer.Buffer, color, s string) {
+	if !enc.color {
+		buf.AppendString(s)
+		return
+	}
+	buf.AppendString(color)
+	buf.AppendString(s)
+	buf.AppendString(colorReset)
+}
+
+// growWidth returns the column width, it grows to n up to prettyMaxColWidth.
+func growWidth(width *atomic.Int64, n int) int {
+	if n > prettyMaxColWidth {
+		n = prettyMaxColWidth
+	}
+	for {
+		cur := width.Load()
+		if int64(n) <= cur || width.CompareAndSwap(cur, int64(n)) {
+			if int64(n) > cur {
+				return n
+			}
+			return int(cur)
+		}
+	}
+}
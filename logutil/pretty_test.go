@@ -0,0 +1,94 @@
+package logutil
+
+import (
+	"errors"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestPrettyEncoder(t *testing.T) {
+	cfg := zap.NewProductionEncoderConfig()
+	enc := NewPrettyEncoder(cfg, false).(*prettyEncoder)
+	enc.AddString("app", "demo")
+	enc.OpenNamespace("req")
+	enc.AddString("id", "1")
+	child := enc.Clone()
+	child.AddString("path", "/users")
+
+	ent := zapcore.Entry{
+		Level:      zapcore.WarnLevel,
+		Time:       enc.start.Add(1500 * time.Millisecond),
+		LoggerName: "db",
+		Caller:     zapcore.NewEntryCaller(0, "/src/app/store/user.go", 42, true),
+		Message:    "slow query",
+		Stack:      "main.main\n\t/src/app/main.go:10",
+	}
+	buf, err := child.EncodeEntry(ent, []zapcore.Field{
+		zap.Object("user", logfmtUser{Name: "foo", Roles: []string{"admin"}}),
+		zap.Error(errors.New("line1\nline2")),
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	want := `  +1.500s WARN  db store/user.go:42 slow query
+    app=demo
+    req={
+      "error": "line1\nline2",
+      "id": "1",
+      "path": "/users",
+      "user": {
+        "name": "foo",
+        "roles": [
+          "admin"
+        ]
+      }
+    }
+    main.main
+    	/src/app/main.go:10
+`
+	if buf.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+
+	// the logger column is as wide as the longest logger seen.
+	buf, _ = NewPrettyEncoder(cfg, false).EncodeEntry(zapcore.Entry{
+		Level:      zapcore.InfoLevel,
+		Time:       time.Now().Add(-time.Second),
+		LoggerName: "http",
+		Message:    "multi",
+	}, []zapcore.Field{zap.String("body", "a\nb"), zap.String("name", "a b")})
+	want = `  +0.000s INFO  http multi
+    body=
+      a
+      b
+    name="a b"
+`
+	if buf.String() != want {
+		t.Fatalf("got\n%s\nwant\n%s", buf.String(), want)
+	}
+}
+
+func TestPrettyColor(t *testing.T) {
+	enc := NewPrettyEncoder(zap.NewProductionEncoderConfig(), true)
+	buf, err := enc.EncodeEntry(zapcore.Entry{Level: zapcore.ErrorLevel, Time: time.Now(), Message: "failed"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if !strings.Contains(buf.String(), colorRed+"ERROR"+colorReset) {
+		t.Fatalf("level not colored %q", buf.String())
+	}
+
+	t.Setenv("NO_COLOR", "1")
+	if colorEnabled(nil) {
+		t.Fatal("color enabled with NO_COLOR")
+	}
+	t.Setenv("NO_COLOR", "")
+	var w strings.Builder
+	if colorEnabled(&w) {
+		t.Fatal("color enabled for non terminal")
+	}
+}
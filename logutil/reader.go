@@ -0,0 +1,553 @@
+package logutil
+
+import (
+	"bufio"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"regexp"
+	"sort"
+	"strconv"
+	"strings"
+	"time"
+
+	"go.uber.org/zap/zapcore"
+)
+
+// lumberjackLayout the time layout of lumberjack backups, e.g.
+// app-2026-10-17T15-04-05.000.log.
+const lumberjackLayout = "2006-01-02T15-04-05.000"
+
+const defaultFollowInterval = 500 * time.Millisecond
+
+// Entry a log entry read from a json log file.
+type Entry struct {
+	Time       time.Time
+	Level      zapcore.Level
+	Logger     string
+	Caller     string
+	Message    string
+	Stacktrace string
+	Fields     map[string]interface{} // 其他字段, 数字为 json.Number
+	File       string                 // 所在文件
+	Raw        string                 // 原始日志行
+}
+
+// Filter entry filter, empty fields match all entries.
+type Filter struct {
+	Level   string            // 最低日志级别
+	Since   time.Time         // 开始时间, 包含
+	Until   time.Time         // 结束时间, 不包含
+	Fields  map[string]string // 字段值, 嵌套字段用 user.id 形式
+	Message string            // 消息正则表达式
+}
+
+// Reader read entries of a log file and its backups written by lumberjack or
+// TimeRotateWriter, compressed backups are read transparently. Lines which
+// are not json are skipped.
+type Reader struct {
+	filename string
+	filter   Filter
+	level    zapcore.Level
+	message  *regexp.Regexp
+
+	// PollInterval the interval to check the file for new entries in Follow,
+	// default 500ms.
+	PollInterval time.Duration
+
+	// Encoding the encoding of the logger writing the file, set it when the
+	// keys or time layout are not the defaults.
+	Encoding *EncodingConfig
+
+	// PrivateKey the RSA private key PEM (PKCS1) to read files encrypted by
+	// TimeRotateWriter.PublicKey.
+	PrivateKey []byte
+}
+
+// NewReader new reader of filename, e.g. ./app.log.
+func NewReader(filename string, filter Filter) (*Reader, error) {
+	r := &Reader{filename: filename, filter: filter, level: zapcore.DebugLevel}
+	if filter.Level != "" {
+		level, err := parseLevel(filter.Level)
+		if err != nil {
+			return nil, err
+		}
+		r.level = level
+	}
+	if filter.Message != "" {
+		re, err := regexp.Compile(filter.Message)
+		if err != nil {
+			return nil, err
+		}
+		r.message = re
+	}
+	return r, nil
+}
+
+// LogFiles returns the backups of filename oldest first and then filename if
+// it exists.
+func LogFiles(filename string) ([]string, error) {
+	dir := filepath.Dir(filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		return nil, err
+	}
+
+	type backup struct {
+		path string
+		time time.Time
+		seq  int
+	}
+	var backups []backup
+	ext := filepath.Ext(filename)
+	prefix := strings.TrimSuffix(filepath.Base(filename), ext) + "-"
+	current := false
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		name := e.Name()
+		if name == filepath.Base(filename) {
+			current = true
+			continue
+		}
+		if f, ok := parseRotatedName(name, prefix, ext, time.Local); ok {
+			backups = append(backups, backup{path: filepath.Join(dir, name), time: f.time, seq: f.seq})
+			continue
+		}
+		stamp := strings.TrimSuffix(name, compressSuffix)
+		if !strings.HasPrefix(stamp, prefix) || !strings.HasSuffix(stamp, ext) {
+			continue
+		}
+		stamp = strings.TrimSuffix(strings.TrimPrefix(stamp, prefix), ext)
+		if t, err := time.Parse(lumberjackLayout, stamp); err == nil {
+			backups = append(backups, backup{path: filepath.Join(dir, name), time: t})
+		}
+	}
+	sort.Slice(backups, func(i, j int) bool {
+		if !backups[i].time.Equal(backups[j].time) {
+			return backups[i].time.Before(backups[j].time)
+		}
+		return backups[i].seq < backups[j].seq
+	})
+
+	files := make([]string, 0, len(backups)+1)
+	for _, b := range backups {
+		files = append(files, b.path)
+	}
+	if current {
+		files = append(files, filename)
+	}
+	return files, nil
+}
+
+// Read call fn with the matched entries of all files oldest first until fn
+// returns false.
+func (r *Reader) Read(fn func(Entry) bool) error {
+	files, err := LogFiles(r.filename)
+	if err != nil {
+		return err
+	}
+	for _, file := range files {
+		next, err := r.readFile(file, fn)
+		if err != nil {
+			return err
+		}
+		if !next {
+			return nil
+		}
+	}
+	return nil
+}
+
+func (r *Reader) readFile(path string, fn func(Entry) bool) (bool, error) {
+	dec := r.decrypter()
+	next, err := readLines(path, func(line []byte) bool {
+		return r.handle(path, line, dec, fn)
+	})
+	if err == nil {
+		err = decryptErr(path, dec)
+	}
+	return next, err
+}
+
+// decrypter returns the decrypter of a file, nil without PrivateKey.
+func (r *Reader) decrypter() *segmentDecrypter {
+	if len(r.PrivateKey) == 0 {
+		return nil
+	}
+	return &segmentDecrypter{privateKey: r.PrivateKey}
+}
+
+func decryptErr(path string, dec *segmentDecrypter) error {
+	if dec == nil || dec.err == nil {
+		return nil
+	}
+	return fmt.Errorf("%s: %w", path, dec.err)
+}
+
+// readLines call fn with the lines of path until fn returns false, it returns
+// false in that case. Compressed files are read transparently and a removed
+// file has no lines.
+func readLines(path string, fn func(line []byte) bool) (bool, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		if os.IsNotExist(err) {
+			// removed by rotation.
+			return true, nil
+		}
+		return false, err
+	}
+	defer f.Close()
+
+	var reader io.Reader = f
+	if strings.HasSuffix(path, compressSuffix) {
+		zr, err := gzip.NewReader(f)
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", path, err)
+		}
+		defer zr.Close()
+		reader = zr
+	}
+
+	br := bufio.NewReader(reader)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 && !fn(line) {
+			return false, nil
+		}
+		if errors.Is(err, io.EOF) {
+			return true, nil
+		}
+		if err != nil {
+			return false, fmt.Errorf("%s: %w", path, err)
+		}
+	}
+}
+
+// handle decrypt, parse and filter the line, it returns false when fn stops
+// or the line can not be decrypted.
+func (r *Reader) handle(path string, line []byte, dec *segmentDecrypter, fn func(Entry) bool) bool {
+	if dec != nil {
+		return dec.line(line, func(line []byte) bool {
+			return r.handle(path, line, nil, fn)
+		})
+	}
+	ent, fields, ok := parseEntry(line, r.Encoding)
+	if !ok || !r.match(ent, fields) {
+		return true
+	}
+	ent.File = path
+	return fn(ent)
+}
+
+// Follow call fn with the matched entries written to the file after Follow
+// is called until ctx is done or fn returns false, like tail -f it keeps
+// reading when the file is rotated.
+func (r *Reader) Follow(ctx context.Context, fn func(Entry) bool) error {
+	interval := r.PollInterval
+	if interval <= 0 {
+		interval = defaultFollowInterval
+	}
+
+	var (
+		f       *os.File
+		path    string
+		partial []byte
+		dec     *segmentDecrypter
+	)
+	defer func() {
+		if f != nil {
+			_ = f.Close()
+		}
+	}()
+
+	// open the current file at its end.
+	path, f = r.openCurrent()
+	dec = r.decrypter()
+	if f != nil {
+		err := r.skipToEnd(f, dec)
+		if err != nil {
+			return err
+		}
+	}
+
+	ticker := time.NewTicker(interval)
+	defer ticker.Stop()
+	for {
+		if f != nil {
+			var next bool
+			var err error
+			partial, next, err = r.readAppended(f, path, partial, dec, fn)
+			if err != nil {
+				return err
+			}
+			if !next {
+				return decryptErr(path, dec)
+			}
+
+			rotated, err := r.rotated(f, path)
+			if err != nil {
+				return err
+			}
+			if rotated {
+				// read what was written before the rotation.
+				partial, next, err = r.readAppended(f, path, partial, dec, fn)
+				if err != nil {
+					return err
+				}
+				if !next {
+					return decryptErr(path, dec)
+				}
+				_ = f.Close()
+				f, partial, dec = nil, nil, r.decrypter()
+			}
+		}
+		if f == nil {
+			path, f = r.openCurrent()
+			if f != nil {
+				continue
+			}
+		}
+
+		select {
+		case <-ctx.Done():
+			return nil
+		case <-ticker.C:
+		}
+	}
+}
+
+// readAppended read the complete lines appended to f, the incomplete last
+// line is returned to be continued.
+func (r *Reader) readAppended(f *os.File, path string, partial []byte, dec *segmentDecrypter, fn func(Entry) bool) ([]byte, bool, error) {
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadBytes('\n')
+		if len(line) > 0 {
+			partial = append(partial, line...)
+		}
+		if len(partial) > 0 && partial[len(partial)-1] == '\n' {
+			if !r.handle(path, partial, dec, fn) {
+				return nil, false, nil
+			}
+			partial = partial[:0]
+		}
+		if errors.Is(err, io.EOF) {
+			return partial, true, nil
+		}
+		if err != nil {
+			return partial, false, err
+		}
+	}
+}
+
+// skipToEnd move to the end of f, the segment headers are read by dec to
+// decrypt the lines appended later.
+func (r *Reader) skipToEnd(f *os.File, dec *segmentDecrypter) error {
+	if dec == nil {
+		_, err := f.Seek(0, io.SeekEnd)
+		return err
+	}
+	br := bufio.NewReader(f)
+	for {
+		line, err := br.ReadBytes('\n')
+		if bytes.HasPrefix(line, []byte(encryptHeader)) && !dec.line(line, nil) {
+			return decryptErr(f.Name(), dec)
+		}
+		if errors.Is(err, io.EOF) {
+			return nil
+		}
+		if err != nil {
+			return err
+		}
+	}
+}
+
+// rotated reports whether path is not the file f anymore, when the file is
+// truncated f is read from the start.
+func (r *Reader) rotated(f *os.File, path string) (bool, error) {
+	info, err := f.Stat()
+	if err != nil {
+		return false, err
+	}
+	offset, err := f.Seek(0, io.SeekCurrent)
+	if err != nil {
+		return false, err
+	}
+	if info.Size() < offset {
+		_, err = f.Seek(0, io.SeekStart)
+		return false, err
+	}
+
+	current := r.currentPath()
+	if current != path {
+		return true, nil
+	}
+	currentInfo, err := os.Stat(current)
+	if err != nil {
+		return true, nil
+	}
+	return !os.SameFile(info, currentInfo), nil
+}
+
+// currentPath returns filename, or the newest file of TimeRotateWriter when
+// filename does not exist.
+func (r *Reader) currentPath() string {
+	if _, err := os.Stat(r.filename); err == nil {
+		return r.filename
+	}
+	files, err := rotatedFiles(r.filename, time.Local)
+	if err != nil {
+		return ""
+	}
+	for i := len(files) - 1; i >= 0; i-- {
+		if !files[i].compressed {
+			return files[i].path
+		}
+	}
+	return ""
+}
+
+func (r *Reader) openCurrent() (string, *os.File) {
+	path := r.currentPath()
+	if path == "" {
+		return "", nil
+	}
+	f, err := os.Open(path)
+	if err != nil {
+		return "", nil
+	}
+	return path, f
+}
+
+func (r *Reader) match(ent Entry, fields map[string]interface{}) bool {
+	if ent.Level < r.level {
+		return false
+	}
+	if !r.filter.Since.IsZero() && ent.Time.Before(r.filter.Since) {
+		return false
+	}
+	if !r.filter.Until.IsZero() && !ent.Time.Before(r.filter.Until) {
+		return false
+	}
+	if r.message != nil && !r.message.MatchString(ent.Message) {
+		return false
+	}
+	for key, want := range r.filter.Fields {
+		value, ok := LookupField(fields, key)
+		if !ok || valueString(value) != want {
+			return false
+		}
+	}
+	return true
+}
+
+// LookupField returns the value of the dotted key in fields decoded from an
+// entry, e.g. user.id in {"user":{"id":1}}, a key containing dots is found too.
+func LookupField(fields map[string]interface{}, key string) (interface{}, bool) {
+	if value, ok := fields[key]; ok {
+		return value, true
+	}
+	for i := 0; i < len(key); i++ {
+		if key[i] != '.' {
+			continue
+		}
+		if nested, ok := fields[key[:i]].(map[string]interface{}); ok {
+			if value, ok := LookupField(nested, key[i+1:]); ok {
+				return value, true
+			}
+		}
+	}
+	return nil, false
+}
+
+// parseEntry parse a json line written with encoding, nil encoding uses
+// the default keys, it returns the entry and all fields.
+func parseEntry(line []byte, encoding *EncodingConfig) (Entry, map[string]interface{}, bool) {
+	line = bytes.TrimSpace(line)
+	if len(line) == 0 || line[0] != '{' {
+		return Entry{}, nil, false
+	}
+	var fields map[string]interface{}
+	decoder := json.NewDecoder(bytes.NewReader(line))
+	decoder.UseNumber()
+	if decoder.Decode(&fields) != nil {
+		return Entry{}, nil, false
+	}
+
+	var keys EncodingKeys
+	if encoding != nil {
+		keys = encoding.Keys
+	}
+	keys = keys.withDefaults()
+	ent := Entry{Raw: string(line), Fields: make(map[string]interface{}, len(fields))}
+	for key, value := range fields {
+		switch key {
+		case keys.Time:
+			ent.Time = parseEntryTime(value, encoding)
+		case keys.Level:
+			s, _ := value.(string)
+			_ = ent.Level.UnmarshalText([]byte(s))
+		case keys.Logger:
+			ent.Logger, _ = value.(string)
+		case keys.Caller:
+			ent.Caller, _ = value.(string)
+		case keys.Message:
+			ent.Message, _ = value.(string)
+		case keys.Stacktrace:
+			ent.Stacktrace, _ = value.(string)
+		default:
+			ent.Fields[key] = value
+		}
+	}
+	return ent, fields, true
+}
+
+// parseEntryTime parse ISO8601 or RFC3339 times, the custom layout of
+// encoding and epoch seconds, or millis and nanos when encoding says so.
+func parseEntryTime(value interface{}, encoding *EncodingConfig) time.Time {
+	var layout string
+	if encoding != nil {
+		layout = encoding.TimeLayout
+	}
+	switch v := value.(type) {
+	case string:
+		if layout, ok := encoding.customLayout(); ok {
+			loc, _ := encoding.location()
+			if loc == nil {
+				loc = time.Local
+			}
+			if t, err := time.ParseInLocation(layout, v, loc); err == nil {
+				return t
+			}
+		}
+		for _, layout := range []string{"2006-01-02T15:04:05.000Z0700", time.RFC3339Nano} {
+			if t, err := time.Parse(layout, v); err == nil {
+				return t
+			}
+		}
+	case json.Number:
+		switch strings.ToLower(layout) {
+		case TimeEpochMillis:
+			if n, err := v.Int64(); err == nil {
+				return time.UnixMilli(n)
+			}
+		case TimeEpochNanos:
+			if n, err := v.Int64(); err == nil {
+				return time.Unix(0, n)
+			}
+		}
+		if f, err := strconv.ParseFloat(v.String(), 64); err == nil {
+			sec := int64(f)
+			return time.Unix(sec, int64((f-float64(sec))*1e9))
+		}
+	}
+	return time.Time{}
+}
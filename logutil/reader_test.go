@@ -0,0 +1,142 @@
+package logutil
+
+import (
+	"compress/gzip"
+	"context"
+	"os"
+	"path/filepath"
+	"testing"
+	"time"
+)
+
+func writeLogFile(t *testing.T, path string, lines ...string) {
+	t.Helper()
+	f, err := os.Create(path)
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer f.Close()
+	if filepath.Ext(path) == compressSuffix {
+		zw := gzip.NewWriter(f)
+		defer zw.Close()
+		for _, line := range lines {
+			_, _ = zw.Write([]byte(line + "\n"))
+		}
+		return
+	}
+	for _, line := range lines {
+		_, _ = f.Write([]byte(line + "\n"))
+	}
+}
+
+func TestReader(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	writeLogFile(t, filepath.Join(dir, "app-2026-10-16T10-00-00.000.log.gz"),
+		`{"level":"INFO","ts":"2026-10-16T09:00:00.000+0000","msg":"start","user":{"id":1}}`,
+		`{"level":"ERROR","ts":"2026-10-16T09:30:00.000+0000","msg":"db failed","user":{"id":2}}`,
+	)
+	writeLogFile(t, filepath.Join(dir, "app-2026-10-17T10-00-00.000.log"),
+		`{"level":"DEBUG","ts":"2026-10-17T09:00:00.000+0000","msg":"db query"}`,
+		`not json`,
+	)
+	writeLogFile(t, filename,
+		`{"level":"WARN","ts":"2026-10-18T09:00:00.000+0000","logger":"db","msg":"db slow","user":{"id":1}}`,
+	)
+
+	read := func(filter Filter) []string {
+		r, err := NewReader(filename, filter)
+		if err != nil {
+			t.Fatal(err)
+		}
+		var messages []string
+		err = r.Read(func(ent Entry) bool {
+			messages = append(messages, ent.Message)
+			return true
+		})
+		if err != nil {
+			t.Fatal(err)
+		}
+		return messages
+	}
+
+	tests := []struct {
+		filter Filter
+		want   []string
+	}{
+		{Filter{}, []string{"start", "db failed", "db query", "db slow"}},
+		{Filter{Level: "warn"}, []string{"db failed", "db slow"}},
+		{Filter{Message: "^db"}, []string{"db failed", "db query", "db slow"}},
+		{Filter{Fields: map[string]string{"user.id": "1"}}, []string{"start", "db slow"}},
+		{Filter{Fields: map[string]string{"logger": "db"}}, []string{"db slow"}},
+		{Filter{
+			Since: time.Date(2026, 10, 16, 9, 30, 0, 0, time.UTC),
+			Until: time.Date(2026, 10, 18, 0, 0, 0, 0, time.UTC),
+		}, []string{"db failed", "db query"}},
+	}
+	for _, tt := range tests {
+		got := read(tt.filter)
+		if len(got) != len(tt.want) {
+			t.Fatalf("filter %+v: got %q, want %q", tt.filter, got, tt.want)
+		}
+		for i := range got {
+			if got[i] != tt.want[i] {
+				t.Fatalf("filter %+v: got %q, want %q", tt.filter, got, tt.want)
+			}
+		}
+	}
+}
+
+func TestReaderFollow(t *testing.T) {
+	dir := t.TempDir()
+	filename := filepath.Join(dir, "app.log")
+	writeLogFile(t, filename, `{"level":"INFO","msg":"old"}`)
+
+	r, err := NewReader(filename, Filter{})
+	if err != nil {
+		t.Fatal(err)
+	}
+	r.PollInterval = 10 * time.Millisecond
+	ctx, cancel := context.WithTimeout(context.Background(), 5*time.Second)
+	defer cancel()
+
+	messages := make(chan string, 10)
+	done := make(chan error, 1)
+	go func() {
+		done <- r.Follow(ctx, func(ent Entry) bool {
+			messages <- ent.Message
+			return ent.Message != "after"
+		})
+	}()
+	time.Sleep(50 * time.Millisecond)
+
+	f, err := os.OpenFile(filename, os.O_APPEND|os.O_WRONLY, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, _ = f.WriteString(`{"level":"INFO","msg":"before"}` + "\n" + `{"level":"INFO",`)
+	time.Sleep(50 * time.Millisecond)
+	_, _ = f.WriteString(`"msg":"rotated"}` + "\n")
+	_ = f.Close()
+
+	// rotate like lumberjack.
+	err = os.Rename(filename, filepath.Join(dir, "app-2026-10-18T10-00-00.000.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	writeLogFile(t, filename, `{"level":"INFO","msg":"after"}`)
+
+	err = <-done
+	if err != nil {
+		t.Fatal(err)
+	}
+	close(messages)
+	var got []string
+	for msg := range messages {
+		got = append(got, msg)
+	}
+	want := []string{"before", "rotated", "after"}
+	if len(got) != len(want) || got[0] != want[0] || got[1] != want[1] || got[2] != want[2] {
+		t.Fatalf("got %q, want %q", got, want)
+	}
+}
@@ -0,0 +1,174 @@
+package logutil
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"os"
+	"runtime/debug"
+	"sort"
+	"strings"
+	"sync/atomic"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// crashReportDir the directory of the crash reports of recovered panics.
+var crashReportDir atomic.Pointer[string]
+
+// SetCrashReportDir write a crash report file to dir for every panic
+// recovered by Recover, Go and RecoverMiddleware, empty dir disables them.
+func SetCrashReportDir(dir string) {
+	if dir == "" {
+		crashReportDir.Store(nil)
+		return
+	}
+	crashReportDir.Store(&dir)
+}
+
+// Recover recover a panic, log it at error with the stack and the fields of
+// the context logger, and sync the logger. It must be deferred directly:
+//
+//	defer logutil.Recover(ctx)
+func Recover(ctx context.Context) {
+	if v := recover(); v != nil {
+		handlePanic(ctx, v)
+	}
+}
+
+// Go run fn in a new goroutine, its panic is recovered by Recover.
+func Go(ctx context.Context, fn func(ctx context.Context)) {
+	go func() {
+		defer Recover(ctx)
+		fn(ctx)
+	}()
+}
+
+// RecoverMiddleware recover the panics of the handlers and reply 500 if the
+// response is not started, the context logger is used when set by
+// ContextMiddleware, l otherwise. http.ErrAbortHandler is not recovered.
+func RecoverMiddleware(l *zap.Logger) func(http.Handler) http.Handler {
+	return func(next http.Handler) http.Handler {
+		return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			ctx := r.Context()
+			if _, ok := ctx.Value(ctxLoggerKey{}).(*ctxLogger); !ok {
+				ctx = WithContext(ctx, l)
+			}
+			rec := &responseRecorder{ResponseWriter: w}
+			defer func() {
+				v := recover()
+				if v == nil {
+					return
+				}
+				if v == http.ErrAbortHandler {
+					panic(v)
+				}
+				var fields []zap.Field
+				if !hasField(ctx, "path") {
+					fields = append(fields, zap.String("method", r.Method), zap.String("path", r.URL.Path))
+				}
+				handlePanic(ctx, v, fields...)
+				if rec.status == 0 {
+					http.Error(rec, http.StatusText(http.StatusInternalServerError), http.StatusInternalServerError)
+				}
+			}()
+			next.ServeHTTP(rec, r.WithContext(ctx))
+		})
+	}
+}
+
+// handlePanic log the recovered value v, write the crash report and sync the
+// logger.
+func handlePanic(ctx context.Context, v interface{}, fields ...zap.Field) {
+	stack := string(debug.Stack())
+	l := FromContext(ctx)
+	fields = append(fields, zap.String("panic", fmt.Sprint(v)), zap.String("stack", stack))
+	if err, ok := v.(error); ok {
+		fields = append(fields, zap.Error(err))
+	}
+	// ContextMiddleware and the grpc interceptors add request_id to the
+	// context logger already.
+	if requestID := RequestIDFromContext(ctx); requestID != "" && !hasField(ctx, "request_id") {
+		fields = append(fields, zap.String("request_id", requestID))
+	}
+
+	if dir := crashReportDir.Load(); dir != nil {
+		path, err := writeCrashReport(*dir, ctx, v, stack)
+		if err != nil {
+			fields = append(fields, zap.NamedError("crash_report_error", err))
+		} else {
+			fields = append(fields, zap.String("crash_report", path))
+		}
+	}
+	l.Error("panic recovered", fields...)
+	_ = l.Sync()
+}
+
+// hasField reports whether a field with key was added to the logger of ctx
+// by WithFields.
+func hasField(ctx context.Context, key string) bool {
+	cl, ok := ctx.Value(ctxLoggerKey{}).(*ctxLogger)
+	if !ok {
+		return false
+	}
+	for _, field := range cl.fields {
+		if field.Key == key {
+			return true
+		}
+	}
+	return false
+}
+
+// writeCrashReport write the panic value, the context fields and the stack
+// to a new file in dir, it returns the file path.
+func writeCrashReport(dir string, ctx context.Context, v interface{}, stack string) (string, error) {
+	err := os.MkdirAll(dir, 0o755)
+	if err != nil {
+		return "", err
+	}
+	now := time.Now()
+	f, err := os.CreateTemp(dir, fmt.Sprintf("crash-%s-%d-*.log", now.Format("20060102T150405"), os.Getpid()))
+	if err != nil {
+		return "", err
+	}
+
+	var b strings.Builder
+	fmt.Fprintf(&b, "time: %s\n", now.Format(time.RFC3339Nano))
+	fmt.Fprintf(&b, "panic: %v\n", v)
+	if requestID := RequestIDFromContext(ctx); requestID != "" {
+		fmt.Fprintf(&b, "request_id: %s\n", requestID)
+	}
+	if cl, ok := ctx.Value(ctxLoggerKey{}).(*ctxLogger); ok && len(cl.fields) > 0 {
+		enc := zapcore.NewMapObjectEncoder()
+		for _, field := range cl.fields {
+			field.AddTo(enc)
+		}
+		keys := make([]string, 0, len(enc.Fields))
+		for key := range enc.Fields {
+			if key == "request_id" {
+				continue
+			}
+			keys = append(keys, key)
+		}
+		sort.Strings(keys)
+		for _, key := range keys {
+			fmt.Fprintf(&b, "%s: %v\n", key, enc.Fields[key])
+		}
+	}
+	b.WriteString("\n")
+	b.WriteString(stack)
+
+	_, err = f.WriteString(b.String())
+	if err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	err = f.Sync()
+	if err != nil {
+		_ = f.Close()
+		return "", err
+	}
+	return f.Name(), f.Close()
+}
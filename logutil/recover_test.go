@@ -0,0 +1,119 @@
+package logutil
+
+import (
+	"bytes"
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestGoRecover(t *testing.T) {
+	dir := t.TempDir()
+	SetCrashReportDir(dir)
+	defer SetCrashReportDir("")
+
+	core, logs := observer.New(zap.DebugLevel)
+	ctx := WithContext(context.Background(), zap.New(core))
+	ctx = WithRequestID(ctx, "req-1")
+	ctx = WithFields(ctx, zap.String("job", "sync"))
+
+	done := make(chan struct{})
+	Go(ctx, func(ctx context.Context) {
+		defer close(done)
+		panic(errors.New("boom"))
+	})
+	<-done
+	for i := 0; i < 100 && logs.Len() == 0; i++ {
+		time.Sleep(10 * time.Millisecond)
+	}
+
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].Message != "panic recovered" {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+	fields := entries[0].ContextMap()
+	if fields["panic"] != "boom" || fields["error"] != "boom" || fields["job"] != "sync" || fields["request_id"] != "req-1" {
+		t.Fatalf("unexpected fields %v", fields)
+	}
+	if stack, _ := fields["stack"].(string); !strings.Contains(stack, "TestGoRecover") {
+		t.Fatalf("unexpected stack %v", fields["stack"])
+	}
+
+	report, _ := fields["crash_report"].(string)
+	if filepath.Dir(report) != dir {
+		t.Fatalf("unexpected crash report %q", report)
+	}
+	data, err := os.ReadFile(report)
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, s := range []string{"panic: boom", "request_id: req-1", "job: sync", "TestGoRecover"} {
+		if !strings.Contains(string(data), s) {
+			t.Fatalf("%q not in crash report\n%s", s, data)
+		}
+	}
+}
+
+func TestRecoverMiddleware(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	handler := RecoverMiddleware(zap.New(core))(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path == "/abort" {
+			panic(http.ErrAbortHandler)
+		}
+		panic("boom")
+	}))
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, "/users", nil))
+	if rec.Code != http.StatusInternalServerError {
+		t.Fatalf("unexpected status %d", rec.Code)
+	}
+	entries := logs.All()
+	if len(entries) != 1 || entries[0].ContextMap()["path"] != "/users" || entries[0].ContextMap()["panic"] != "boom" {
+		t.Fatalf("unexpected entries %v", entries)
+	}
+
+	func() {
+		defer func() {
+			if v := recover(); v != http.ErrAbortHandler {
+				t.Fatalf("abort not repanicked, got %v", v)
+			}
+		}()
+		handler.ServeHTTP(httptest.NewRecorder(), httptest.NewRequest(http.MethodGet, "/abort", nil))
+	}()
+}
+
+func TestRecoverMiddlewareRequestContext(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := New(&Config{LogLevel: "info", LogFormat: "json"}, WithOutputs(Output{Type: OutputWriter, Writer: &buf}))
+	if err != nil {
+		t.Fatal(err)
+	}
+	handler := ContextMiddleware(l)(RecoverMiddleware(l)(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("boom")
+	})))
+
+	req := httptest.NewRequest(http.MethodGet, "/users", nil)
+	req.Header.Set(RequestIDHeader, "req-1")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// the fields of the context logger are not added again.
+	out := buf.String()
+	for _, key := range []string{`"request_id":"req-1"`, `"method":"GET"`, `"path":"/users"`} {
+		if n := strings.Count(out, key); n != 1 {
+			t.Errorf("%s found %d times in %s", key, n, out)
+		}
+	}
+	if strings.Count(out, `"request_id"`) != 1 {
+		t.Errorf("duplicate request_id in %s", out)
+	}
+}
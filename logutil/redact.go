@@ -0,0 +1,344 @@
+package logutil
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"regexp"
+	"strings"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// redact modes.
+const (
+	RedactMask = "mask"
+	RedactHash = "hash"
+)
+
+const redactMask = "******"
+
+// RedactConfig redact config.
+type RedactConfig struct {
+	Fields   []string `json:"fields" yaml:"fields"`     // 需要脱敏的字段名, 不区分大小写, 字段名包含即匹配, 如 password 匹配 db_password
+	Patterns []string `json:"patterns" yaml:"patterns"` // 需要脱敏的值的正则, 作用于字符串字段的值, 日志内容和堆栈, 如邮箱
+	Cards    bool     `json:"cards" yaml:"cards"`       // 是否脱敏通过 Luhn 校验的 13-19 位卡号
+	Integers bool     `json:"integers" yaml:"integers"` // 是否按 Patterns 和 Cards 检查整数字段的十进制值, 时间戳和 ID 可能被误判
+	Mode     string   `json:"mode" yaml:"mode"`         // 脱敏方式 mask hash, 默认 mask
+}
+
+// cardPattern the candidates of card numbers, digits optionally separated by
+// spaces or dashes, they are redacted only when they pass the Luhn check.
+var cardPattern = regexp.MustCompile(`\b(?:\d[ \-]?){12,18}\d\b`)
+
+// DefaultRedactConfig returns rules for passwords, tokens, card numbers and
+// emails. Integer fields are only redacted by their keys, e.g. card_number.
+func DefaultRedactConfig() RedactConfig {
+	return RedactConfig{
+		Fields: []string{
+			"password", "passwd", "pwd", "secret", "token", "api_key", "apikey",
+			"authorization", "cookie", "card_number", "cardnumber", "credit_card", "cvv",
+		},
+		Patterns: []string{
+			`[A-Za-z0-9._%+\-]+@[A-Za-z0-9.\-]+\.[A-Za-z]{2,}`, // email
+			`(?i)bearer\s+[A-Za-z0-9\-._~+/]+=*`,               // bearer token
+		},
+		Cards: true,
+		Mode:  RedactMask,
+	}
+}
+
+// Redactor mask or hash sensitive fields and values.
+type Redactor struct {
+	fields   []string
+	patterns []*regexp.Regexp
+	cards    bool
+	integers bool
+	mode     string
+}
+
+// NewRedactor new redactor.
+func NewRedactor(cfg RedactConfig) (*Redactor, error) {
+	r := &Redactor{cards: cfg.Cards, integers: cfg.Integers, mode: cfg.Mode}
+	switch r.mode {
+	case "":
+		r.mode = RedactMask
+	case RedactMask, RedactHash:
+	default:
+		return nil, fmt.Errorf("unknown redact mode %q", cfg.Mode)
+	}
+
+	for _, field := range cfg.Fields {
+		if field != "" {
+			r.fields = append(r.fields, strings.ToLower(field))
+		}
+	}
+	for _, pattern := range cfg.Patterns {
+		re, err := regexp.Compile(pattern)
+		if err != nil {
+			return nil, fmt.Errorf("redact pattern %q: %w", pattern, err)
+		}
+		r.patterns = append(r.patterns, re)
+	}
+	return r, nil
+}
+
+// RedactString redact the values matched by the patterns and the card
+// numbers in s.
+func (r *Redactor) RedactString(s string) string {
+	for _, re := range r.patterns {
+		s = re.ReplaceAllStringFunc(s, r.replace)
+	}
+	if r.cards {
+		s = cardPattern.ReplaceAllStringFunc(s, func(match string) string {
+			if !luhnValid(match) {
+				return match
+			}
+			return r.replace(match)
+		})
+	}
+	return s
+}
+
+// luhnValid reports whether the digits of s pass the Luhn check.
+func luhnValid(s string) bool {
+	sum, n := 0, 0
+	for i := len(s) - 1; i >= 0; i-- {
+		c := s[i]
+		if c < '0' || c > '9' {
+			continue
+		}
+		d := int(c - '0')
+		if n%2 == 1 {
+			d *= 2
+			if d > 9 {
+				d -= 9
+			}
+		}
+		sum += d
+		n++
+	}
+	return n > 0 && sum%10 == 0
+}
+
+// RedactField redact the field by its key, or the matched values in it.
+func (r *Redactor) RedactField(f zapcore.Field) zapcore.Field {
+	f, _ = r.redactField(f)
+	return f
+}
+
+// RedactFields redact fields, fields are copied when any is changed.
+func (r *Redactor) RedactFields(fields []zapcore.Field) []zapcore.Field {
+	var redacted []zapcore.Field
+	for i, f := range fields {
+		rf, changed := r.redactField(f)
+		if redacted == nil {
+			if !changed {
+				continue
+			}
+			redacted = make([]zapcore.Field, len(fields))
+			copy(redacted, fields[:i])
+		}
+		redacted[i] = rf
+	}
+	if redacted == nil {
+		return fields
+	}
+	return redacted
+}
+
+func (r *Redactor) redactField(f zapcore.Field) (zapcore.Field, bool) {
+	if f.Type == zapcore.NamespaceType || f.Type == zapcore.SkipType {
+		return f, false
+	}
+	if r.matchKey(f.Key) {
+		return zap.String(f.Key, r.replace(fieldString(f))), true
+	}
+
+	switch f.Type {
+	case zapcore.StringType, zapcore.ByteStringType, zapcore.ErrorType, zapcore.StringerType:
+		s := fieldString(f)
+		if redacted := r.RedactString(s); redacted != s {
+			return zap.String(f.Key, redacted), true
+		}
+	case zapcore.Int64Type, zapcore.Int32Type, zapcore.Int16Type, zapcore.Int8Type,
+		zapcore.Uint64Type, zapcore.Uint32Type, zapcore.Uint16Type, zapcore.Uint8Type, zapcore.UintptrType:
+		// integers are checked in decimal only when enabled, e.g. card numbers
+		// logged by zap.Int64.
+		if !r.integers {
+			return f, false
+		}
+		s := fieldString(f)
+		if redacted := r.RedactString(s); redacted != s {
+			return zap.String(f.Key, redacted), true
+		}
+	case zapcore.ObjectMarshalerType, zapcore.ArrayMarshalerType, zapcore.ReflectType:
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		if redacted, changed := r.redactValue(enc.Fields[f.Key]); changed {
+			return zap.Any(f.Key, redacted), true
+		}
+	case zapcore.InlineMarshalerType:
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		if redacted, changed := r.redactValue(enc.Fields); changed {
+			return zap.Inline(redactedObject(redacted.(map[string]interface{}))), true
+		}
+	}
+	return f, false
+}
+
+func (r *Redactor) matchKey(key string) bool {
+	key = strings.ToLower(key)
+	for _, field := range r.fields {
+		if strings.Contains(key, field) {
+			return true
+		}
+	}
+	return false
+}
+
+func (r *Redactor) replace(value string) string {
+	if r.mode == RedactHash {
+		sum := sha256.Sum256([]byte(value))
+		return "sha256:" + hex.EncodeToString(sum[:8])
+	}
+	return redactMask
+}
+
+// redactValue redact values decoded by MapObjectEncoder or json.
+func (r *Redactor) redactValue(value interface{}) (interface{}, bool) {
+	switch v := value.(type) {
+	case string:
+		s := r.RedactString(v)
+		return s, s != v
+	case []byte:
+		s := r.RedactString(string(v))
+		return s, s != string(v)
+	case map[string]interface{}:
+		changed := false
+		redacted := make(map[string]interface{}, len(v))
+		for key, item := range v {
+			if r.matchKey(key) {
+				redacted[key] = r.replace(fmt.Sprint(item))
+				changed = true
+				continue
+			}
+			rv, ok := r.redactValue(item)
+			redacted[key] = rv
+			changed = changed || ok
+		}
+		return redacted, changed
+	case []interface{}:
+		changed := false
+		redacted := make([]interface{}, len(v))
+		for i, item := range v {
+			rv, ok := r.redactValue(item)
+			redacted[i] = rv
+			changed = changed || ok
+		}
+		return redacted, changed
+	case int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr:
+		if !r.integers {
+			return v, false
+		}
+		s := fmt.Sprint(v)
+		if redacted := r.RedactString(s); redacted != s {
+			return redacted, true
+		}
+		return v, false
+	case json.Number:
+		if r.integers {
+			if redacted := r.RedactString(v.String()); redacted != v.String() {
+				return redacted, true
+			}
+		}
+		if i, err := v.Int64(); err == nil {
+			return i, false
+		}
+		f, _ := v.Float64()
+		return f, false
+	case nil, bool, float32, float64, complex64, complex128:
+		return v, false
+	}
+
+	// reflected values, inspect their json form.
+	data, err := json.Marshal(value)
+	if err != nil {
+		return value, false
+	}
+	var generic interface{}
+	decoder := json.NewDecoder(bytes.NewReader(data))
+	decoder.UseNumber()
+	err = decoder.Decode(&generic)
+	if err != nil {
+		return value, false
+	}
+	if redacted, changed := r.redactValue(generic); changed {
+		return redacted, true
+	}
+	return value, false
+}
+
+// fieldString returns the field value as string.
+func fieldString(f zapcore.Field) string {
+	switch f.Type {
+	case zapcore.StringType:
+		return f.String
+	case zapcore.ByteStringType:
+		return string(f.Interface.([]byte))
+	case zapcore.ErrorType:
+		if err, ok := f.Interface.(error); ok && err != nil {
+			return err.Error()
+		}
+	case zapcore.StringerType:
+		if s, ok := f.Interface.(fmt.Stringer); ok && s != nil {
+			return s.String()
+		}
+	}
+	enc := zapcore.NewMapObjectEncoder()
+	f.AddTo(enc)
+	return fmt.Sprint(enc.Fields[f.Key])
+}
+
+type redactedObject map[string]interface{}
+
+func (o redactedObject) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for key, value := range o {
+		zap.Any(key, value).AddTo(enc)
+	}
+	return nil
+}
+
+// redactCore redact entries before writing them to the core, it must wrap
+// a core which only checks the level in Check like zapcore.NewCore.
+type redactCore struct {
+	zapcore.Core
+	redactor *Redactor
+}
+
+// NewRedactCore new core redacting message, stack and fields before writing
+// to core.
+func NewRedactCore(core zapcore.Core, redactor *Redactor) zapcore.Core {
+	return &redactCore{Core: core, redactor: redactor}
+}
+
+func (c *redactCore) With(fields []zapcore.Field) zapcore.Core {
+	return &redactCore{Core: c.Core.With(c.redactor.RedactFields(fields)), redactor: c.redactor}
+}
+
+func (c *redactCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *redactCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	ent.Message = c.redactor.RedactString(ent.Message)
+	ent.Stack = c.redactor.RedactString(ent.Stack)
+	return c.Core.Write(ent, c.redactor.RedactFields(fields))
+}
@@ -0,0 +1,115 @@
+package logutil_test
+
+import (
+	"bytes"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/booyangcc/utils/logutil"
+	"github.com/booyangcc/utils/logutil/logtest"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+type account struct {
+	User     string `json:"user"`
+	Password string `json:"password"`
+	Card     string `json:"card"`
+}
+
+func TestRedact(t *testing.T) {
+	var buf bytes.Buffer
+	l, err := logutil.New(&logutil.Config{LogLevel: "info", LogFormat: "json"},
+		logutil.WithOutputs(logutil.Output{Type: logutil.OutputWriter, Writer: &buf}),
+		logutil.WithRedact(logutil.DefaultRedactConfig()),
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	l = l.With(zap.String("api_token", "tok-123"))
+	l.Info("login bob@example.com",
+		zap.String("db_password", "hunter2"),
+		zap.Any("account", account{User: "bob", Password: "s3cret", Card: "4111 1111 1111 1111"}),
+		zap.Strings("emails", []string{"alice@example.com"}),
+		zap.Error(errors.New("auth: Bearer abc.def.ghi rejected")),
+		zap.Int("count", 1),
+	)
+
+	out := buf.String()
+	logtest.AssertNoLeak(t, out, "tok-123", "bob@example.com", "hunter2", "s3cret",
+		"4111 1111 1111 1111", "alice@example.com", "abc.def.ghi")
+	for _, want := range []string{`"user":"bob"`, `"count":1`, `"msg":"login ******"`} {
+		if !strings.Contains(out, want) {
+			t.Errorf("output %s does not contain %s", out, want)
+		}
+	}
+}
+
+func TestRedactHash(t *testing.T) {
+	r, err := logutil.NewRedactor(logutil.RedactConfig{Fields: []string{"password"}, Mode: logutil.RedactHash})
+	if err != nil {
+		t.Fatal(err)
+	}
+	a := r.RedactField(zap.String("password", "hunter2"))
+	b := r.RedactField(zap.String("Password", "hunter2"))
+	if a.String != b.String || !strings.HasPrefix(a.String, "sha256:") {
+		t.Errorf("got %s and %s", a.String, b.String)
+	}
+}
+
+type payment struct {
+	Amount int   `json:"amount"`
+	PAN    int64 `json:"pan"`
+}
+
+func TestRedactIntegersAndStack(t *testing.T) {
+	cfg := logutil.DefaultRedactConfig()
+	r, err := logutil.NewRedactor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	core, logs := observer.New(zap.InfoLevel)
+	l := zap.New(logutil.NewRedactCore(core, r))
+
+	// integers are not checked by value by default, only by key.
+	l.Info("created", zap.Int64("ts_ms", 1760000000000), zap.Int64("pan", 4111111111111111), zap.Int64("card_number", 4111111111111111))
+	fields := logs.TakeAll()[0].ContextMap()
+	if fields["ts_ms"] != int64(1760000000000) || fields["pan"] != int64(4111111111111111) || fields["card_number"] != "******" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+
+	cfg.Integers = true
+	r, err = logutil.NewRedactor(cfg)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l = zap.New(logutil.NewRedactCore(core, r))
+	l.Info("paid",
+		zap.Int64("pan", 4111111111111111),
+		zap.Any("payment", payment{Amount: 12, PAN: 4111111111111111}),
+		zap.Int("amount", 12),
+		// 13-19 digits failing the Luhn check, e.g. timestamps and ids.
+		zap.Int64("ts_ns", 1760000000000000001),
+		zap.String("order", "order 4111111111111112"),
+	)
+	err = l.Core().Write(zapcore.Entry{Level: zapcore.ErrorLevel, Message: "failed", Stack: "handler(bob@example.com)\n\tmain.go:12"}, nil)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	entries := logs.All()
+	fields = entries[0].ContextMap()
+	if fields["pan"] != "******" || fields["amount"] != int64(12) || fields["ts_ns"] != int64(1760000000000000001) || fields["order"] != "order 4111111111111112" {
+		t.Errorf("unexpected fields %v", fields)
+	}
+	if p, ok := fields["payment"].(map[string]interface{}); !ok || p["pan"] != "******" || p["amount"] != int64(12) {
+		t.Errorf("unexpected payment %#v", fields["payment"])
+	}
+	if stack := entries[1].Stack; strings.Contains(stack, "bob@example.com") || !strings.Contains(stack, "main.go:12") {
+		t.Errorf("unexpected stack %q", stack)
+	}
+}
@@ -0,0 +1,283 @@
+package logutil
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"io"
+	"io/fs"
+	"sort"
+	"sync"
+	"sync/atomic"
+	"syscall"
+
+	"go.uber.org/zap"
+)
+
+// sinks the closers and file paths of the outputs of a logger.
+type sinks struct {
+	closers []io.Closer
+	paths   []func() string
+	dropped map[string][]func() uint64 // dropped entry counters by source
+	metrics *Metrics                   // metrics counting the dropped entries, may be nil
+}
+
+// add add a closer and the func returning its file path, path is nil for
+// outputs without file.
+func (s *sinks) add(closer io.Closer, path func() string) {
+	s.closers = append(s.closers, closer)
+	if path != nil {
+		s.paths = append(s.paths, path)
+	}
+}
+
+// addDropped add the counter of entries dropped by source.
+func (s *sinks) addDropped(source string, count func() uint64) {
+	if s.dropped == nil {
+		s.dropped = make(map[string][]func() uint64)
+	}
+	s.dropped[source] = append(s.dropped[source], count)
+}
+
+// close close the sinks in reverse order, so async writers are flushed
+// before their files are closed, and remove them from the metrics.
+func (s *sinks) close() error {
+	if s.metrics != nil {
+		s.metrics.removeSinks(s)
+	}
+	var errs []error
+	for i := len(s.closers) - 1; i >= 0; i-- {
+		if err := s.closers[i].Close(); err != nil {
+			errs = append(errs, err)
+		}
+	}
+	s.closers = nil
+	return errors.Join(errs...)
+}
+
+// droppedCounts returns the dropped entries by source.
+func (s *sinks) droppedCounts() map[string]uint64 {
+	counts := make(map[string]uint64, len(s.dropped))
+	for source, counters := range s.dropped {
+		for _, count := range counters {
+			counts[source] += count()
+		}
+	}
+	return counts
+}
+
+func (s *sinks) filePaths() []string {
+	paths := make([]string, 0, len(s.paths))
+	for _, path := range s.paths {
+		paths = append(paths, path())
+	}
+	return paths
+}
+
+// lastSinks the sinks of the logger created last by New.
+var lastSinks atomic.Pointer[sinks]
+
+type registered struct {
+	logger *zap.Logger
+	level  zap.AtomicLevel
+	sinks  *sinks
+}
+
+// Registry owns named loggers and their outputs, Shutdown flushes and closes
+// all of them.
+type Registry struct {
+	mu      sync.Mutex
+	loggers map[string]*registered
+	restore []func() // undo ReplaceGlobals
+}
+
+// NewRegistry new registry.
+func NewRegistry() *Registry {
+	return &Registry{loggers: make(map[string]*registered)}
+}
+
+var defaultRegistry = NewRegistry()
+
+// DefaultRegistry returns the registry used by the package level functions.
+func DefaultRegistry() *Registry {
+	return defaultRegistry
+}
+
+// Register create the logger name, see New for cfg and opts. The logger is
+// named by name so module levels apply to it.
+func (r *Registry) Register(name string, cfg *Config, opts ...Option) (*zap.Logger, error) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if _, ok := r.loggers[name]; ok {
+		return nil, fmt.Errorf("logger %q already registered", name)
+	}
+
+	l, level, s, err := newLogger(cfg, opts...)
+	if err != nil {
+		return nil, err
+	}
+	if name != "" {
+		l = l.Named(name)
+	}
+	r.loggers[name] = &registered{logger: l, level: level, sinks: s}
+	return l, nil
+}
+
+// Logger returns the logger name, zap.L().Named(name) is returned when it
+// is not registered.
+func (r *Registry) Logger(name string) *zap.Logger {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	if reg, ok := r.loggers[name]; ok {
+		return reg.logger
+	}
+	if name == "" {
+		return zap.L()
+	}
+	return zap.L().Named(name)
+}
+
+// Level returns the atomic level of the logger name.
+func (r *Registry) Level(name string) (zap.AtomicLevel, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reg, ok := r.loggers[name]
+	if !ok {
+		return zap.AtomicLevel{}, false
+	}
+	return reg.level, true
+}
+
+// Dropped returns the entries dropped by the outputs of the logger name by
+// source, e.g. DroppedAsync for async writers with a full buffer.
+func (r *Registry) Dropped(name string) (map[string]uint64, bool) {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reg, ok := r.loggers[name]
+	if !ok {
+		return nil, false
+	}
+	return reg.sinks.droppedCounts(), true
+}
+
+// Names returns the names of the registered loggers.
+func (r *Registry) Names() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	return r.sortedNames()
+}
+
+// ReplaceGlobals replace zap.L, zap.S and the standard library log package
+// output with the logger name, they are restored by Shutdown.
+func (r *Registry) ReplaceGlobals(name string) error {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	reg, ok := r.loggers[name]
+	if !ok {
+		return fmt.Errorf("logger %q not registered", name)
+	}
+	r.restore = append(r.restore, zap.ReplaceGlobals(reg.logger), zap.RedirectStdLog(reg.logger))
+	return nil
+}
+
+// FilePaths returns the log files being written by the registered loggers.
+func (r *Registry) FilePaths() []string {
+	r.mu.Lock()
+	defer r.mu.Unlock()
+	var paths []string
+	for _, name := range r.sortedNames() {
+		paths = append(paths, r.loggers[name].sinks.filePaths()...)
+	}
+	return paths
+}
+
+// Shutdown restore the globals, sync all loggers and close their outputs,
+// the loggers and their metrics are removed from the registry. It returns ctx.Err() when ctx is
+// done first, the outputs are still closed in background.
+func (r *Registry) Shutdown(ctx context.Context) error {
+	r.mu.Lock()
+	names := r.sortedNames()
+	loggers := r.loggers
+	restore := r.restore
+	r.loggers = make(map[string]*registered)
+	r.restore = nil
+	r.mu.Unlock()
+
+	for i := len(restore) - 1; i >= 0; i-- {
+		restore[i]()
+	}
+
+	done := make(chan error, 1)
+	go func() {
+		var errs []error
+		for _, name := range names {
+			reg := loggers[name]
+			// stdout and stderr may not support sync.
+			if err := reg.logger.Sync(); err != nil && !isInvalidSync(err) {
+				errs = append(errs, fmt.Errorf("sync logger %q: %w", name, err))
+			}
+			if err := reg.sinks.close(); err != nil {
+				errs = append(errs, fmt.Errorf("close logger %q: %w", name, err))
+			}
+			if reg.sinks.metrics != nil {
+				reg.sinks.metrics.removeLogger(name)
+			}
+		}
+		done <- errors.Join(errs...)
+	}()
+
+	select {
+	case err := <-done:
+		return err
+	case <-ctx.Done():
+		return ctx.Err()
+	}
+}
+
+func (r *Registry) sortedNames() []string {
+	names := make([]string, 0, len(r.loggers))
+	for name := range r.loggers {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+	return names
+}
+
+// isInvalidSync reports whether err is returned by syncing a terminal or pipe.
+func isInvalidSync(err error) bool {
+	var pathErr *fs.PathError
+	return errors.As(err, &pathErr) && (errors.Is(pathErr.Err, syscall.EINVAL) || errors.Is(pathErr.Err, syscall.ENOTTY))
+}
+
+// Register create the logger name in the default registry.
+func Register(name string, cfg *Config, opts ...Option) (*zap.Logger, error) {
+	return defaultRegistry.Register(name, cfg, opts...)
+}
+
+// Logger returns the logger name of the default registry.
+func Logger(name string) *zap.Logger {
+	return defaultRegistry.Logger(name)
+}
+
+// ReplaceGlobals replace the globals with the logger name of the default
+// registry.
+func ReplaceGlobals(name string) error {
+	return defaultRegistry.ReplaceGlobals(name)
+}
+
+// Dropped returns the dropped entries of the logger name of the default
+// registry.
+func Dropped(name string) (map[string]uint64, bool) {
+	return defaultRegistry.Dropped(name)
+}
+
+// LogFilePaths returns the log files of the default registry.
+func LogFilePaths() []string {
+	return defaultRegistry.FilePaths()
+}
+
+// Shutdown shutdown the default registry, call it before exit so the
+// buffered entries are written.
+func Shutdown(ctx context.Context) error {
+	return defaultRegistry.Shutdown(ctx)
+}
@@ -0,0 +1,104 @@
+package logutil
+
+import (
+	"context"
+	"log"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+)
+
+func TestRegistry(t *testing.T) {
+	dir := t.TempDir()
+	r := NewRegistry()
+	l, err := r.Register("app", &Config{
+		LogLevel:    "info",
+		LogFormat:   "json",
+		LogPath:     dir,
+		LogFileName: "app.log",
+		LogType:     LogFile,
+		Async:       &AsyncConfig{FlushInterval: time.Hour},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	_, err = r.Register("app", nil)
+	if err == nil {
+		t.Fatal("expected duplicate error")
+	}
+	_, err = r.Register("daily", &Config{
+		LogPath:     dir,
+		LogFileName: "daily.log",
+		LogType:     LogFile,
+		LogRotate:   RotateDaily,
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	paths := r.FilePaths()
+	daily := rotatedName(filepath.Join(dir, "daily.log"), time.Now().Format(dailyLayout), 0)
+	if len(paths) != 2 || paths[0] != filepath.Join(dir, "app.log") || paths[1] != daily {
+		t.Fatalf("unexpected paths %q", paths)
+	}
+	if r.Logger("app") != l {
+		t.Fatal("registered logger not returned")
+	}
+
+	err = r.ReplaceGlobals("app")
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Info("direct")
+	zap.L().Info("global")
+	log.Print("std")
+
+	err = r.Shutdown(context.Background())
+	if err != nil {
+		t.Fatal(err)
+	}
+	if zap.L() == l {
+		t.Fatal("globals not restored")
+	}
+	if len(r.Names()) != 0 {
+		t.Fatalf("loggers not removed %q", r.Names())
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "app.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	for _, msg := range []string{`"msg":"direct"`, `"msg":"global"`, `"msg":"std"`, `"logger":"app"`} {
+		if !strings.Contains(string(data), msg) {
+			t.Fatalf("%s not flushed, got %s", msg, data)
+		}
+	}
+}
+
+func TestRegisterOutputError(t *testing.T) {
+	dir := t.TempDir()
+	notDir := filepath.Join(dir, "file")
+	err := os.WriteFile(notDir, nil, 0o644)
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	r := NewRegistry()
+	_, err = r.Register("app", &Config{
+		LogLevel: "info",
+		Outputs: []Output{
+			{Type: OutputFile, Path: dir, FileName: "app.log", Rotate: RotateDaily},
+			{Type: OutputHTTP, HTTP: &HTTPConfig{URL: "http://127.0.0.1:1", SpoolDir: filepath.Join(notDir, "spool")}},
+		},
+	})
+	if err == nil {
+		t.Fatal("expected spool dir error")
+	}
+	if len(r.Names()) != 0 {
+		t.Fatalf("failed logger registered %q", r.Names())
+	}
+}
@@ -0,0 +1,423 @@
+package logutil
+
+import (
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+)
+
+// rotate modes.
+const (
+	RotateSize   = "size"
+	RotateDaily  = "daily"
+	RotateHourly = "hourly"
+)
+
+const (
+	dailyLayout    = "2006-01-02"
+	hourlyLayout   = "2006-01-02T15"
+	compressSuffix = ".gz"
+)
+
+// megabyte is a var so tests can change it.
+var megabyte int64 = 1024 * 1024
+
+// TimeRotateWriter write logs to a file per day or hour named by date, e.g.
+// app.log is written to app-2026-10-17.log or app-2026-10-17T15.log. When
+// MaxSize is set the file is also rotated by size inside the period, the next
+// files are app-2026-10-17.1.log, app-2026-10-17.2.log and so on.
+//
+// When PublicKey is set every opened file starts a segment with a new AES-GCM
+// data key wrapped by the public key, each write is sealed with it. Read the
+// files with Reader.PrivateKey or NewDecryptReader.
+type TimeRotateWriter struct {
+	Filename   string // 日志文件路径, 实际文件名会加上日期
+	Interval   string // 分割周期 daily hourly, 默认 daily
+	MaxSize    int    // 单个日志文件最大多少 mb, 0 不限制
+	MaxBackups int    // 日志备份文件最多数量, 0 不限制
+	MaxAge     int    // 日志保留时间，单位: 天 (day), 0 不限制
+	Compress   bool   // 是否压缩分割后的日志
+	LocalTime  bool   // 是否使用本地时间命名, 默认 UTC
+	PublicKey  []byte // RSA 公钥 PEM (PKIX), 设置后加密写入
+
+	mu      sync.Mutex
+	file    *os.File
+	current string
+	size    int64
+	period  string
+	seq     int
+	enc     *segmentEncrypter
+
+	millMu sync.Mutex
+	millWg sync.WaitGroup
+
+	now func() time.Time
+}
+
+// Write write p to the file of the current period.
+func (w *TimeRotateWriter) Write(p []byte) (int, error) {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	period := w.currentTime().Format(w.layout())
+	if w.file == nil || period != w.period {
+		err := w.openPeriod(period)
+		if err != nil {
+			return 0, err
+		}
+	} else if maxSize := int64(w.MaxSize) * megabyte; maxSize > 0 && w.size > 0 && w.size+int64(len(p)) > maxSize {
+		err := w.openFile(w.period, w.seq+1)
+		if err != nil {
+			return 0, err
+		}
+	}
+
+	if w.enc == nil {
+		n, err := w.file.Write(p)
+		w.size += int64(n)
+		return n, err
+	}
+	line, err := w.enc.seal(p)
+	if err != nil {
+		return 0, err
+	}
+	n, err := w.file.Write(line)
+	w.size += int64(n)
+	if err != nil {
+		return 0, err
+	}
+	return len(p), nil
+}
+
+// Sync commit the current file to disk.
+func (w *TimeRotateWriter) Sync() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.file == nil {
+		return nil
+	}
+	return w.file.Sync()
+}
+
+// Close close the current file and wait for the rotated files to be
+// compressed and removed.
+func (w *TimeRotateWriter) Close() error {
+	w.mu.Lock()
+	err := w.close()
+	w.mu.Unlock()
+	w.millWg.Wait()
+	return err
+}
+
+// CurrentFile returns the file being written, or the file of the current
+// period before the first write.
+func (w *TimeRotateWriter) CurrentFile() string {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.current != "" {
+		return w.current
+	}
+	return rotatedName(w.Filename, w.currentTime().Format(w.layout()), 0)
+}
+
+// Rotate close the current file and open the next one of the period.
+func (w *TimeRotateWriter) Rotate() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+
+	period := w.currentTime().Format(w.layout())
+	if w.file == nil || period != w.period {
+		return w.openPeriod(period)
+	}
+	return w.openFile(period, w.seq+1)
+}
+
+func (w *TimeRotateWriter) close() error {
+	if w.file == nil {
+		return nil
+	}
+	err := w.file.Close()
+	w.file = nil
+	return err
+}
+
+func (w *TimeRotateWriter) currentTime() time.Time {
+	now := time.Now
+	if w.now != nil {
+		now = w.now
+	}
+	if w.LocalTime {
+		return now()
+	}
+	return now().UTC()
+}
+
+func (w *TimeRotateWriter) location() *time.Location {
+	if w.LocalTime {
+		return time.Local
+	}
+	return time.UTC
+}
+
+func (w *TimeRotateWriter) layout() string {
+	if w.Interval == RotateHourly {
+		return hourlyLayout
+	}
+	return dailyLayout
+}
+
+// openPeriod open the last file of the period, it is appended if it is not full.
+func (w *TimeRotateWriter) openPeriod(period string) error {
+	files, err := rotatedFiles(w.Filename, w.location())
+	if err != nil {
+		return err
+	}
+	seq := 0
+	for _, f := range files {
+		if f.period != period || f.seq < seq {
+			continue
+		}
+		seq = f.seq
+		if f.compressed {
+			seq++
+		}
+	}
+	return w.openFile(period, seq)
+}
+
+func (w *TimeRotateWriter) openFile(period string, seq int) error {
+	err := w.close()
+	if err != nil {
+		return err
+	}
+
+	err = os.MkdirAll(filepath.Dir(w.Filename), 0o755)
+	if err != nil {
+		return err
+	}
+	name := rotatedName(w.Filename, period, seq)
+	if maxSize := int64(w.MaxSize) * megabyte; maxSize > 0 {
+		for {
+			info, err := os.Stat(name)
+			if err != nil || info.Size() < maxSize {
+				break
+			}
+			seq++
+			name = rotatedName(w.Filename, period, seq)
+		}
+	}
+
+	f, err := os.OpenFile(name, os.O_CREATE|os.O_WRONLY|os.O_APPEND, 0o644)
+	if err != nil {
+		return err
+	}
+	info, err := f.Stat()
+	if err != nil {
+		_ = f.Close()
+		return err
+	}
+	size := info.Size()
+	var enc *segmentEncrypter
+	if len(w.PublicKey) > 0 {
+		var n int
+		enc, n, err = newSegmentEncrypter(f, w.PublicKey)
+		size += int64(n)
+		if err != nil {
+			_ = f.Close()
+			return err
+		}
+	}
+	w.file = f
+	w.enc = enc
+	w.current = name
+	w.size = size
+	w.period = period
+	w.seq = seq
+
+	w.millWg.Add(1)
+	go func() {
+		defer w.millWg.Done()
+		w.mill()
+	}()
+	return nil
+}
+
+// mill compress and remove old files except the current one.
+func (w *TimeRotateWriter) mill() {
+	w.millMu.Lock()
+	defer w.millMu.Unlock()
+
+	files, err := rotatedFiles(w.Filename, w.location())
+	if err != nil {
+		return
+	}
+	// files listed before are older than or the same as the current file.
+	w.mu.Lock()
+	current := w.current
+	w.mu.Unlock()
+
+	var remove []rotatedFile
+	if w.MaxAge > 0 {
+		cutoff := w.currentTime().Add(-time.Duration(w.MaxAge) * 24 * time.Hour)
+		var keep []rotatedFile
+		for _, f := range files {
+			if f.path != current && f.time.Before(cutoff) {
+				remove = append(remove, f)
+				continue
+			}
+			keep = append(keep, f)
+		}
+		files = keep
+	}
+	if w.MaxBackups > 0 {
+		var keep []rotatedFile
+		backups := 0
+		for i := len(files) - 1; i >= 0; i-- {
+			f := files[i]
+			if f.path == current {
+				keep = append(keep, f)
+				continue
+			}
+			backups++
+			if backups > w.MaxBackups {
+				remove = append(remove, f)
+				continue
+			}
+			keep = append(keep, f)
+		}
+		files = keep
+	}
+
+	for _, f := range remove {
+		_ = os.Remove(f.path)
+	}
+	if !w.Compress {
+		return
+	}
+	for _, f := range files {
+		if f.path == current || f.compressed {
+			continue
+		}
+		_ = compressFile(f.path, f.path+compressSuffix)
+	}
+}
+
+// rotatedFile a file written by TimeRotateWriter.
+type rotatedFile struct {
+	path       string
+	period     string
+	time       time.Time
+	seq        int
+	compressed bool
+}
+
+// rotatedName returns the file name of the period, dir/app.log is
+// dir/app-2026-10-17.log and dir/app-2026-10-17.1.log when seq is 1.
+func rotatedName(filename, period string, seq int) string {
+	ext := filepath.Ext(filename)
+	base := strings.TrimSuffix(filename, ext)
+	if seq > 0 {
+		return fmt.Sprintf("%s-%s.%d%s", base, period, seq, ext)
+	}
+	return fmt.Sprintf("%s-%s%s", base, period, ext)
+}
+
+// rotatedFiles returns files of filename sorted by period and seq, the
+// periods in the names are parsed in loc.
+func rotatedFiles(filename string, loc *time.Location) ([]rotatedFile, error) {
+	dir := filepath.Dir(filename)
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		if os.IsNotExist(err) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	ext := filepath.Ext(filename)
+	prefix := strings.TrimSuffix(filepath.Base(filename), ext) + "-"
+	var files []rotatedFile
+	for _, e := range entries {
+		if e.IsDir() {
+			continue
+		}
+		f, ok := parseRotatedName(e.Name(), prefix, ext, loc)
+		if !ok {
+			continue
+		}
+		f.path = filepath.Join(dir, e.Name())
+		files = append(files, f)
+	}
+	sort.Slice(files, func(i, j int) bool {
+		if !files[i].time.Equal(files[j].time) {
+			return files[i].time.Before(files[j].time)
+		}
+		return files[i].seq < files[j].seq
+	})
+	return files, nil
+}
+
+func parseRotatedName(name, prefix, ext string, loc *time.Location) (rotatedFile, bool) {
+	var f rotatedFile
+	if strings.HasSuffix(name, compressSuffix) {
+		f.compressed = true
+		name = strings.TrimSuffix(name, compressSuffix)
+	}
+	if !strings.HasPrefix(name, prefix) || !strings.HasSuffix(name, ext) {
+		return f, false
+	}
+	stamp := strings.TrimSuffix(strings.TrimPrefix(name, prefix), ext)
+	if i := strings.IndexByte(stamp, '.'); i >= 0 {
+		seq, err := strconv.Atoi(stamp[i+1:])
+		if err != nil || seq <= 0 {
+			return f, false
+		}
+		f.seq = seq
+		stamp = stamp[:i]
+	}
+	for _, layout := range []string{dailyLayout, hourlyLayout} {
+		t, err := time.ParseInLocation(layout, stamp, loc)
+		if err == nil {
+			f.period = stamp
+			f.time = t
+			return f, true
+		}
+	}
+	return f, false
+}
+
+// compressFile gzip src to dst and remove src.
+func compressFile(src, dst string) error {
+	in, err := os.Open(src)
+	if err != nil {
+		return err
+	}
+	defer func() {
+		_ = in.Close()
+	}()
+
+	out, err := os.OpenFile(dst, os.O_CREATE|os.O_WRONLY|os.O_TRUNC, 0o644)
+	if err != nil {
+		return err
+	}
+	gz := gzip.NewWriter(out)
+	_, err = io.Copy(gz, in)
+	if err == nil {
+		err = gz.Close()
+	}
+	if closeErr := out.Close(); err == nil {
+		err = closeErr
+	}
+	if err != nil {
+		_ = os.Remove(dst)
+		return err
+	}
+	return os.Remove(src)
+}
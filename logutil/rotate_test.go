@@ -0,0 +1,70 @@
+package logutil
+
+import (
+	"os"
+	"path/filepath"
+	"sort"
+	"testing"
+	"time"
+)
+
+func TestTimeRotateWriter(t *testing.T) {
+	megabyte = 1
+	defer func() {
+		megabyte = 1024 * 1024
+	}()
+
+	dir := t.TempDir()
+	now := time.Date(2026, 10, 17, 10, 0, 0, 0, time.UTC)
+	w := &TimeRotateWriter{
+		Filename:   filepath.Join(dir, "app.log"),
+		Interval:   RotateDaily,
+		MaxSize:    10,
+		MaxBackups: 2,
+		Compress:   true,
+		now:        func() time.Time { return now },
+	}
+
+	write := func(p string) {
+		_, err := w.Write([]byte(p))
+		if err != nil {
+			t.Fatal(err)
+		}
+	}
+	write("day1-a\n")
+	write("day1-b\n") // exceeds 10 bytes, goes to app-2026-10-17.1.log
+	now = now.Add(24 * time.Hour)
+	write("day2-a\n")
+	now = now.Add(24 * time.Hour)
+	write("day3-a\n")
+	_ = w.Close()
+	w.millWg.Wait()
+
+	entries, err := os.ReadDir(dir)
+	if err != nil {
+		t.Fatal(err)
+	}
+	var names []string
+	for _, e := range entries {
+		names = append(names, e.Name())
+	}
+	sort.Strings(names)
+
+	want := []string{"app-2026-10-17.1.log.gz", "app-2026-10-18.log.gz", "app-2026-10-19.log"}
+	if len(names) != len(want) {
+		t.Fatalf("got files %v, want %v", names, want)
+	}
+	for i := range want {
+		if names[i] != want[i] {
+			t.Fatalf("got files %v, want %v", names, want)
+		}
+	}
+
+	data, err := os.ReadFile(filepath.Join(dir, "app-2026-10-19.log"))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if string(data) != "day3-a\n" {
+		t.Errorf("got %q", data)
+	}
+}
@@ -0,0 +1,368 @@
+package logutil
+
+import (
+	"fmt"
+	"sync"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+const (
+	defaultSamplingTick      = time.Second
+	defaultRateLimitInterval = time.Minute
+)
+
+// SamplingConfig sampling config, in every Tick the first Initial entries
+// with the same level and message are logged, then every Thereafter-th.
+type SamplingConfig struct {
+	Level      string        `json:"level" yaml:"level"`           // 生效的日志级别, 为空时作用于其他未配置的级别
+	Tick       time.Duration `json:"tick" yaml:"tick"`             // 采样周期, 默认 1s
+	Initial    int           `json:"initial" yaml:"initial"`       // 每个周期内先输出的条数
+	Thereafter int           `json:"thereafter" yaml:"thereafter"` // 之后每 Thereafter 条输出一条, 0 表示全部丢弃
+}
+
+// RateLimitConfig rate limit config, in every Interval at most Limit entries
+// with the same level and message are logged, the suppressed entries are
+// reported by a summary entry after the interval, or on Sync and Close.
+type RateLimitConfig struct {
+	Level    string        `json:"level" yaml:"level"`       // 生效的日志级别, 为空时作用于其他未配置的级别
+	Interval time.Duration `json:"interval" yaml:"interval"` // 限流周期, 默认 1m
+	Limit    int           `json:"limit" yaml:"limit"`       // 每个周期内同一消息最多输出的条数, 必须大于 0
+}
+
+// configLevels returns the index of the config by level, the config without
+// level is used for levels without their own.
+func configLevels(levels []string) (map[zapcore.Level]int, error) {
+	byLevel := make(map[zapcore.Level]int)
+	fallback := -1
+	for i, name := range levels {
+		if name == "" {
+			fallback = i
+			continue
+		}
+		level, err := parseLevel(name)
+		if err != nil {
+			return nil, err
+		}
+		byLevel[level] = i
+	}
+	if fallback >= 0 {
+		for level := zapcore.DebugLevel; level <= zapcore.FatalLevel; level++ {
+			if _, ok := byLevel[level]; !ok {
+				byLevel[level] = fallback
+			}
+		}
+	}
+	return byLevel, nil
+}
+
+// samplingCore sample entries with the sampler of their level.
+type samplingCore struct {
+	zapcore.Core
+	samplers map[zapcore.Level]zapcore.Core
+}
+
+// NewSamplingCore new core sampling entries by level.
+func NewSamplingCore(core zapcore.Core, configs ...SamplingConfig) (zapcore.Core, error) {
+	return newSamplingCore(core, nil, configs...)
+}
+
+// newSamplingCore new sampling core, hook is called with the sampling
+// decisions when not nil.
+func newSamplingCore(core zapcore.Core, hook func(zapcore.Entry, zapcore.SamplingDecision), configs ...SamplingConfig) (zapcore.Core, error) {
+	levels := make([]string, len(configs))
+	for i, cfg := range configs {
+		levels[i] = cfg.Level
+	}
+	byLevel, err := configLevels(levels)
+	if err != nil {
+		return nil, err
+	}
+
+	var opts []zapcore.SamplerOption
+	if hook != nil {
+		opts = append(opts, zapcore.SamplerHook(hook))
+	}
+	samplers := make(map[zapcore.Level]zapcore.Core, len(byLevel))
+	for level, i := range byLevel {
+		cfg := configs[i]
+		tick := cfg.Tick
+		if tick <= 0 {
+			tick = defaultSamplingTick
+		}
+		samplers[level] = zapcore.NewSamplerWithOptions(core, tick, cfg.Initial, cfg.Thereafter, opts...)
+	}
+	return &samplingCore{Core: core, samplers: samplers}, nil
+}
+
+func (c *samplingCore) With(fields []zapcore.Field) zapcore.Core {
+	samplers := make(map[zapcore.Level]zapcore.Core, len(c.samplers))
+	for level, sampler := range c.samplers {
+		samplers[level] = sampler.With(fields)
+	}
+	return &samplingCore{Core: c.Core.With(fields), samplers: samplers}
+}
+
+func (c *samplingCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if sampler, ok := c.samplers[ent.Level]; ok {
+		return sampler.Check(ent, ce)
+	}
+	return c.Core.Check(ent, ce)
+}
+
+type rateLimitKey struct {
+	level   zapcore.Level
+	logger  string
+	message string
+}
+
+type rateLimitCounter struct {
+	start      time.Time
+	count      int
+	suppressed int
+}
+
+// rateLimiter state shared by the rate limit cores created by With.
+type rateLimiter struct {
+	configs    map[zapcore.Level]RateLimitConfig
+	mu         sync.Mutex
+	counters   map[rateLimitKey]*rateLimitCounter
+	lastSweep  time.Time
+	now        func() time.Time
+	onSuppress func(zapcore.Level) // called with the level of suppressed entries, may be nil
+
+	// the summaries of suppressed entries are written by write every tick
+	// while entries are suppressed.
+	tick   time.Duration
+	timer  *time.Timer
+	closed bool
+	write  func([]rateLimitSummary)
+}
+
+type rateLimitCore struct {
+	zapcore.Core
+	limiter *rateLimiter
+}
+
+// NewRateLimitCore new core limiting entries with the same level and message.
+// The returned core is an io.Closer, Close writes the pending summaries and
+// stops their timer.
+func NewRateLimitCore(core zapcore.Core, configs ...RateLimitConfig) (zapcore.Core, error) {
+	c, err := newRateLimitCore(core, nil, configs...)
+	if err != nil {
+		return nil, err
+	}
+	return c, nil
+}
+
+// newRateLimitCore new rate limit core, onSuppress is called with the level
+// of the suppressed entries when not nil.
+func newRateLimitCore(core zapcore.Core, onSuppress func(zapcore.Level), configs ...RateLimitConfig) (*rateLimitCore, error) {
+	levels := make([]string, len(configs))
+	for i, cfg := range configs {
+		levels[i] = cfg.Level
+	}
+	byLevel, err := configLevels(levels)
+	if err != nil {
+		return nil, err
+	}
+
+	limits := make(map[zapcore.Level]RateLimitConfig, len(byLevel))
+	var tick time.Duration
+	for level, i := range byLevel {
+		cfg := configs[i]
+		if cfg.Limit <= 0 {
+			return nil, fmt.Errorf("rate limit of %s: limit must be positive, got %d", level, cfg.Limit)
+		}
+		if cfg.Interval <= 0 {
+			cfg.Interval = defaultRateLimitInterval
+		}
+		if tick == 0 || cfg.Interval < tick {
+			tick = cfg.Interval
+		}
+		limits[level] = cfg
+	}
+
+	c := &rateLimitCore{
+		Core: core,
+		limiter: &rateLimiter{
+			configs:    limits,
+			counters:   make(map[rateLimitKey]*rateLimitCounter),
+			now:        time.Now,
+			onSuppress: onSuppress,
+			tick:       tick,
+		},
+	}
+	// the summaries of the timer are written without the fields of With.
+	c.limiter.write = c.writeSummaries
+	return c, nil
+}
+
+func (c *rateLimitCore) With(fields []zapcore.Field) zapcore.Core {
+	return &rateLimitCore{Core: c.Core.With(fields), limiter: c.limiter}
+}
+
+func (c *rateLimitCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	cfg, ok := c.limiter.configs[ent.Level]
+	if !ok || !c.Enabled(ent.Level) {
+		return c.Core.Check(ent, ce)
+	}
+
+	// only the entries accepted by the inner core, e.g. not muted by module
+	// levels or sampling, spend the budget.
+	checked := c.Core.Check(ent, nil)
+	if checked == nil {
+		return ce
+	}
+	allowed, summaries := c.limiter.allow(ent, cfg)
+	c.writeSummaries(summaries)
+	if !allowed {
+		if c.limiter.onSuppress != nil {
+			c.limiter.onSuppress(ent.Level)
+		}
+		return ce
+	}
+	return ce.AddCore(ent, checkedCore{Core: c.Core, ce: checked})
+}
+
+// checkedCore write an entry already checked by the inner core, so the inner
+// Check runs once.
+type checkedCore struct {
+	zapcore.Core
+	ce *zapcore.CheckedEntry
+}
+
+func (c checkedCore) Write(_ zapcore.Entry, fields []zapcore.Field) error {
+	c.ce.Write(fields...)
+	return nil
+}
+
+// Sync write the pending summaries and sync the core.
+func (c *rateLimitCore) Sync() error {
+	c.writeSummaries(c.limiter.flush())
+	return c.Core.Sync()
+}
+
+// Close write the pending summaries and stop the summary timer.
+func (c *rateLimitCore) Close() error {
+	l := c.limiter
+	l.mu.Lock()
+	l.closed = true
+	if l.timer != nil {
+		l.timer.Stop()
+		l.timer = nil
+	}
+	summaries := l.sweep(l.now(), true)
+	l.mu.Unlock()
+	c.writeSummaries(summaries)
+	return nil
+}
+
+type rateLimitSummary struct {
+	key        rateLimitKey
+	suppressed int
+	interval   time.Duration
+}
+
+func (c *rateLimitCore) writeSummaries(summaries []rateLimitSummary) {
+	for _, s := range summaries {
+		ent := zapcore.Entry{
+			Level:      s.key.level,
+			Time:       c.limiter.now(),
+			LoggerName: s.key.logger,
+			Message:    fmt.Sprintf("suppressed %d similar messages", s.suppressed),
+		}
+		if ce := c.Core.Check(ent, nil); ce != nil {
+			ce.Write(
+				zap.String("suppressed_msg", s.key.message),
+				zap.Int("suppressed", s.suppressed),
+				zap.Duration("interval", s.interval),
+			)
+		}
+	}
+}
+
+// allow count the entry, it returns the summaries of the expired intervals.
+func (l *rateLimiter) allow(ent zapcore.Entry, cfg RateLimitConfig) (bool, []rateLimitSummary) {
+	now := l.now()
+	key := rateLimitKey{level: ent.Level, logger: ent.LoggerName, message: ent.Message}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+
+	var summaries []rateLimitSummary
+	if now.Sub(l.lastSweep) >= cfg.Interval {
+		summaries = l.sweep(now, false)
+		l.lastSweep = now
+	}
+
+	counter, ok := l.counters[key]
+	if !ok {
+		counter = &rateLimitCounter{start: now}
+		l.counters[key] = counter
+	} else if now.Sub(counter.start) >= cfg.Interval {
+		if counter.suppressed > 0 {
+			summaries = append(summaries, rateLimitSummary{key: key, suppressed: counter.suppressed, interval: cfg.Interval})
+		}
+		*counter = rateLimitCounter{start: now}
+	}
+
+	if counter.count < cfg.Limit {
+		counter.count++
+		return true, summaries
+	}
+	counter.suppressed++
+	if l.timer == nil && !l.closed {
+		l.timer = time.AfterFunc(l.tick, l.onTick)
+	}
+	return false, summaries
+}
+
+// onTick write the summaries of the expired intervals, the timer is reset
+// while there are suppressed entries.
+func (l *rateLimiter) onTick() {
+	l.mu.Lock()
+	if l.closed {
+		l.mu.Unlock()
+		return
+	}
+	now := l.now()
+	summaries := l.sweep(now, false)
+	l.lastSweep = now
+	l.timer = nil
+	for _, counter := range l.counters {
+		if counter.suppressed > 0 {
+			l.timer = time.AfterFunc(l.tick, l.onTick)
+			break
+		}
+	}
+	l.mu.Unlock()
+	l.write(summaries)
+}
+
+// flush returns the summaries of all counters with suppressed entries.
+func (l *rateLimiter) flush() []rateLimitSummary {
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	return l.sweep(l.now(), true)
+}
+
+// sweep remove the expired counters and returns their summaries, all
+// suppressed counters are reported and reset when force is true.
+func (l *rateLimiter) sweep(now time.Time, force bool) []rateLimitSummary {
+	var summaries []rateLimitSummary
+	for key, counter := range l.counters {
+		interval := l.configs[key.level].Interval
+		expired := now.Sub(counter.start) >= interval
+		if counter.suppressed > 0 && (expired || force) {
+			summaries = append(summaries, rateLimitSummary{key: key, suppressed: counter.suppressed, interval: interval})
+			counter.suppressed = 0
+		}
+		if expired {
+			delete(l.counters, key)
+		}
+	}
+	return summaries
+}
@@ -0,0 +1,152 @@
+package logutil
+
+import (
+	"io"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSamplingCore(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	sampled, err := NewSamplingCore(core,
+		SamplingConfig{Level: "info", Initial: 2, Thereafter: 3, Tick: time.Hour},
+		SamplingConfig{Initial: 1, Thereafter: 0, Tick: time.Hour},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(sampled)
+	for i := 0; i < 10; i++ {
+		l.Info("info")
+		l.Warn("warn")
+	}
+	l.Info("other")
+
+	// info: the first 2 then every 3rd, warn: the first only.
+	counts := map[string]int{}
+	for _, ent := range logs.All() {
+		counts[ent.Message]++
+	}
+	if counts["info"] != 4 || counts["warn"] != 1 || counts["other"] != 1 {
+		t.Fatalf("unexpected counts %v", counts)
+	}
+
+	_, err = NewSamplingCore(core, SamplingConfig{Level: "verbose"})
+	if err == nil {
+		t.Fatal("expected level error")
+	}
+}
+
+func TestRateLimitCore(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	limited, err := NewRateLimitCore(core,
+		RateLimitConfig{Level: "warn", Limit: 2, Interval: 50 * time.Millisecond},
+		RateLimitConfig{Level: "error", Limit: 1, Interval: time.Hour},
+	)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(limited)
+	for i := 0; i < 5; i++ {
+		l.Warn("disk full")
+		l.Named("db").Warn("disk full")
+		l.Info("not limited")
+	}
+	for i := 0; i < 3; i++ {
+		l.Error("conn refused")
+	}
+	if n := logs.FilterMessage("disk full").Len(); n != 4 {
+		t.Fatalf("got %d warn entries, want 2 per logger", n)
+	}
+	if n := logs.FilterMessage("not limited").Len(); n != 5 {
+		t.Fatalf("got %d info entries", n)
+	}
+	if n := logs.FilterMessage("conn refused").Len(); n != 1 {
+		t.Fatalf("got %d error entries", n)
+	}
+
+	// the warn summaries are written by the timer without more entries.
+	summaries := logs.FilterMessage("suppressed 3 similar messages")
+	for i := 0; i < 100 && summaries.Len() < 2; i++ {
+		time.Sleep(10 * time.Millisecond)
+		summaries = logs.FilterMessage("suppressed 3 similar messages")
+	}
+	if summaries.Len() != 2 {
+		t.Fatalf("got %d warn summaries", summaries.Len())
+	}
+	var loggers []string
+	for _, ent := range summaries.All() {
+		fields := ent.ContextMap()
+		if ent.Level != zapcore.WarnLevel || fields["suppressed_msg"] != "disk full" || fields["suppressed"] != int64(3) {
+			t.Fatalf("unexpected summary %v %v", ent.Entry, fields)
+		}
+		loggers = append(loggers, ent.LoggerName)
+	}
+	if strings.Join(loggers, ",") != ",db" && strings.Join(loggers, ",") != "db," {
+		t.Fatalf("unexpected summary loggers %q", loggers)
+	}
+
+	// the error interval has not expired, Close writes its summary.
+	if logs.FilterMessage("suppressed 2 similar messages").Len() != 0 {
+		t.Fatal("error summary written before its interval")
+	}
+	err = limited.(io.Closer).Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if logs.FilterMessage("suppressed 2 similar messages").FilterField(zap.String("suppressed_msg", "conn refused")).Len() != 1 {
+		t.Fatal("error summary not written on close")
+	}
+}
+
+func TestRateLimitInnerCheck(t *testing.T) {
+	core, logs := observer.New(zap.DebugLevel)
+	levels := NewModuleLevels(zap.NewAtomicLevelAt(zapcore.DebugLevel))
+	err := levels.Set("debug,db=info")
+	if err != nil {
+		t.Fatal(err)
+	}
+	suppressed := 0
+	limited, err := newRateLimitCore(newModuleCore(core, levels), func(zapcore.Level) { suppressed++ },
+		RateLimitConfig{Level: "debug", Limit: 1, Interval: time.Hour})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(limited)
+	// the muted module entries are not counted nor suppressed.
+	for i := 0; i < 3; i++ {
+		l.Named("db").Debug("query")
+	}
+	l.Debug("query")
+	l.Debug("query")
+	err = limited.Close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if suppressed != 1 {
+		t.Fatalf("got %d suppressed entries, want 1", suppressed)
+	}
+	if got := messages(logs); got != "query,suppressed 1 similar messages" {
+		t.Fatalf("got %q", got)
+	}
+	if logs.All()[1].LoggerName != "" {
+		t.Fatalf("summary of the muted logger %q", logs.All()[1].LoggerName)
+	}
+}
+
+func TestRateLimitInvalid(t *testing.T) {
+	_, err := NewRateLimitCore(zapcore.NewNopCore(), RateLimitConfig{Level: "warn"})
+	if err == nil {
+		t.Fatal("expected limit error")
+	}
+	cfg := Config{LogLevel: "info", RateLimit: []RateLimitConfig{{Level: "warn", Limit: 0}}}
+	err = cfg.Validate()
+	if err == nil || !strings.Contains(err.Error(), "rate_limit[0].limit") {
+		t.Fatalf("unexpected error %v", err)
+	}
+}
@@ -0,0 +1,285 @@
+package logutil
+
+import (
+	"context"
+	"log/slog"
+	"runtime"
+	"sort"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+// slogHandler slog handler writing through a zap core.
+type slogHandler struct {
+	core   zapcore.Core
+	name   string
+	groups []string // groups without attrs yet, they are opened by the next attrs
+}
+
+// NewSlogHandler new slog handler writing through the core of l, so slog and
+// zap loggers share the same outputs, levels and formats.
+func NewSlogHandler(l *zap.Logger) slog.Handler {
+	return &slogHandler{core: l.Core(), name: l.Name()}
+}
+
+func (h *slogHandler) Enabled(_ context.Context, level slog.Level) bool {
+	return h.core.Enabled(zapLevel(level))
+}
+
+func (h *slogHandler) Handle(_ context.Context, r slog.Record) error {
+	ent := zapcore.Entry{
+		Level:      zapLevel(r.Level),
+		Time:       r.Time,
+		LoggerName: h.name,
+		Message:    r.Message,
+	}
+	if r.PC != 0 {
+		frame, _ := runtime.CallersFrames([]uintptr{r.PC}).Next()
+		ent.Caller = zapcore.EntryCaller{
+			Defined:  frame.PC != 0,
+			PC:       frame.PC,
+			File:     frame.File,
+			Line:     frame.Line,
+			Function: frame.Function,
+		}
+	}
+
+	ce := h.core.Check(ent, nil)
+	if ce == nil {
+		return nil
+	}
+
+	fields := make([]zapcore.Field, 0, r.NumAttrs())
+	r.Attrs(func(attr slog.Attr) bool {
+		if field, ok := attrField(attr); ok {
+			fields = append(fields, field)
+		}
+		return true
+	})
+	if len(fields) > 0 {
+		fields = append(h.namespaces(), fields...)
+	}
+	ce.Write(fields...)
+	return nil
+}
+
+func (h *slogHandler) WithAttrs(attrs []slog.Attr) slog.Handler {
+	fields := make([]zapcore.Field, 0, len(attrs))
+	for _, attr := range attrs {
+		if field, ok := attrField(attr); ok {
+			fields = append(fields, field)
+		}
+	}
+	if len(fields) == 0 {
+		return h
+	}
+	return &slogHandler{
+		core: h.core.With(append(h.namespaces(), fields...)),
+		name: h.name,
+	}
+}
+
+func (h *slogHandler) WithGroup(name string) slog.Handler {
+	if name == "" {
+		return h
+	}
+	groups := make([]string, len(h.groups), len(h.groups)+1)
+	copy(groups, h.groups)
+	return &slogHandler{core: h.core, name: h.name, groups: append(groups, name)}
+}
+
+func (h *slogHandler) namespaces() []zapcore.Field {
+	fields := make([]zapcore.Field, 0, len(h.groups))
+	for _, group := range h.groups {
+		fields = append(fields, zap.Namespace(group))
+	}
+	return fields
+}
+
+// attrField convert slog attr to zap field, empty attrs and groups are dropped.
+func attrField(attr slog.Attr) (zapcore.Field, bool) {
+	value := attr.Value.Resolve()
+	if attr.Key == "" && value.Kind() != slog.KindGroup && value.Any() == nil {
+		return zap.Skip(), false
+	}
+
+	switch value.Kind() {
+	case slog.KindString:
+		return zap.String(attr.Key, value.String()), true
+	case slog.KindInt64:
+		return zap.Int64(attr.Key, value.Int64()), true
+	case slog.KindUint64:
+		return zap.Uint64(attr.Key, value.Uint64()), true
+	case slog.KindFloat64:
+		return zap.Float64(attr.Key, value.Float64()), true
+	case slog.KindBool:
+		return zap.Bool(attr.Key, value.Bool()), true
+	case slog.KindDuration:
+		return zap.Duration(attr.Key, value.Duration()), true
+	case slog.KindTime:
+		return zap.Time(attr.Key, value.Time()), true
+	case slog.KindGroup:
+		attrs := value.Group()
+		if len(attrs) == 0 {
+			return zap.Skip(), false
+		}
+		if attr.Key == "" {
+			return zap.Inline(slogGroup(attrs)), true
+		}
+		return zap.Object(attr.Key, slogGroup(attrs)), true
+	}
+
+	if err, ok := value.Any().(error); ok {
+		return zap.NamedError(attr.Key, err), true
+	}
+	return zap.Any(attr.Key, value.Any()), true
+}
+
+type slogGroup []slog.Attr
+
+func (g slogGroup) MarshalLogObject(enc zapcore.ObjectEncoder) error {
+	for _, attr := range g {
+		if field, ok := attrField(attr); ok {
+			field.AddTo(enc)
+		}
+	}
+	return nil
+}
+
+// zapLevel convert slog level to zap level.
+func zapLevel(level slog.Level) zapcore.Level {
+	switch {
+	case level < slog.LevelInfo:
+		return zapcore.DebugLevel
+	case level < slog.LevelWarn:
+		return zapcore.InfoLevel
+	case level < slog.LevelError:
+		return zapcore.WarnLevel
+	}
+	return zapcore.ErrorLevel
+}
+
+// slogLevel convert zap level to slog level, levels above error are
+// slog.LevelError+1 and so on.
+func slogLevel(level zapcore.Level) slog.Level {
+	switch level {
+	case zapcore.DebugLevel:
+		return slog.LevelDebug
+	case zapcore.InfoLevel:
+		return slog.LevelInfo
+	case zapcore.WarnLevel:
+		return slog.LevelWarn
+	}
+	return slog.LevelError + slog.Level(level-zapcore.ErrorLevel)
+}
+
+// slogCore zap core writing through a slog handler.
+type slogCore struct {
+	handler slog.Handler
+}
+
+// NewSlogCore new zap core writing through the slog handler, use it with
+// zap.New to log with zap APIs to slog handlers.
+func NewSlogCore(handler slog.Handler) zapcore.Core {
+	return &slogCore{handler: handler}
+}
+
+func (c *slogCore) Enabled(level zapcore.Level) bool {
+	return c.handler.Enabled(context.Background(), slogLevel(level))
+}
+
+func (c *slogCore) With(fields []zapcore.Field) zapcore.Core {
+	handler := c.handler
+	start := 0
+	for i, f := range fields {
+		if f.Type != zapcore.NamespaceType {
+			continue
+		}
+		if attrs := fieldAttrs(fields[start:i]); len(attrs) > 0 {
+			handler = handler.WithAttrs(attrs)
+		}
+		handler = handler.WithGroup(f.Key)
+		start = i + 1
+	}
+	if attrs := fieldAttrs(fields[start:]); len(attrs) > 0 {
+		handler = handler.WithAttrs(attrs)
+	}
+	return &slogCore{handler: handler}
+}
+
+func (c *slogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *slogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	var pc uintptr
+	if ent.Caller.Defined {
+		pc = ent.Caller.PC
+	}
+	r := slog.NewRecord(ent.Time, slogLevel(ent.Level), ent.Message, pc)
+	if ent.LoggerName != "" {
+		r.AddAttrs(slog.String("logger", ent.LoggerName))
+	}
+	r.AddAttrs(fieldAttrs(fields)...)
+	if ent.Stack != "" {
+		r.AddAttrs(slog.String("stacktrace", ent.Stack))
+	}
+	return c.handler.Handle(context.Background(), r)
+}
+
+func (c *slogCore) Sync() error {
+	return nil
+}
+
+// fieldAttrs convert zap fields to slog attrs, fields after a namespace are
+// grouped under it.
+func fieldAttrs(fields []zapcore.Field) []slog.Attr {
+	attrs := make([]slog.Attr, 0, len(fields))
+	for i, f := range fields {
+		if f.Type == zapcore.NamespaceType {
+			group := fieldAttrs(fields[i+1:])
+			if len(group) > 0 {
+				attrs = append(attrs, slog.Attr{Key: f.Key, Value: slog.GroupValue(group...)})
+			}
+			return attrs
+		}
+		if f.Type == zapcore.SkipType {
+			continue
+		}
+
+		enc := zapcore.NewMapObjectEncoder()
+		f.AddTo(enc)
+		if value, ok := enc.Fields[f.Key]; ok && len(enc.Fields) == 1 {
+			attrs = append(attrs, valueAttr(f.Key, value))
+			continue
+		}
+		// error and inline fields add more than one key.
+		attrs = append(attrs, mapAttrs(enc.Fields)...)
+	}
+	return attrs
+}
+
+func mapAttrs(m map[string]interface{}) []slog.Attr {
+	keys := make([]string, 0, len(m))
+	for key := range m {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+
+	attrs := make([]slog.Attr, 0, len(m))
+	for _, key := range keys {
+		attrs = append(attrs, valueAttr(key, m[key]))
+	}
+	return attrs
+}
+
+func valueAttr(key string, value interface{}) slog.Attr {
+	if m, ok := value.(map[string]interface{}); ok {
+		return slog.Attr{Key: key, Value: slog.GroupValue(mapAttrs(m)...)}
+	}
+	return slog.Any(key, value)
+}
@@ -0,0 +1,58 @@
+package logutil
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"log/slog"
+	"testing"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zaptest/observer"
+)
+
+func TestSlogHandler(t *testing.T) {
+	core, logs := observer.New(zap.InfoLevel)
+	l := slog.New(NewSlogHandler(zap.New(core).Named("api")))
+
+	l.Debug("hidden")
+	l.WithGroup("req").With("id", 7).Warn("slow", "ms", 120, slog.Group("user", "name", "bob"))
+
+	entries := logs.All()
+	if len(entries) != 1 {
+		t.Fatalf("got %d entries", len(entries))
+	}
+	ent := entries[0]
+	if ent.Level != zap.WarnLevel || ent.LoggerName != "api" || ent.Message != "slow" {
+		t.Errorf("got entry %+v", ent.Entry)
+	}
+	req, ok := ent.ContextMap()["req"].(map[string]interface{})
+	if !ok {
+		t.Fatalf("got fields %v", ent.ContextMap())
+	}
+	user, _ := req["user"].(map[string]interface{})
+	if req["id"] != int64(7) || req["ms"] != int64(120) || user["name"] != "bob" {
+		t.Errorf("got fields %v", ent.ContextMap())
+	}
+}
+
+func TestSlogCore(t *testing.T) {
+	var buf bytes.Buffer
+	handler := slog.NewJSONHandler(&buf, &slog.HandlerOptions{Level: slog.LevelInfo})
+	l := zap.New(NewSlogCore(handler)).Named("db")
+
+	l.Debug("hidden")
+	l.With(zap.String("app", "demo"), zap.Namespace("query")).Error("failed",
+		zap.Int("rows", 3), zap.Error(errors.New("timeout")))
+
+	var got map[string]interface{}
+	err := json.Unmarshal(buf.Bytes(), &got)
+	if err != nil {
+		t.Fatalf("unmarshal %s: %v", buf.String(), err)
+	}
+	query, _ := got["query"].(map[string]interface{})
+	if got["level"] != "ERROR" || got["msg"] != "failed" || got["app"] != "demo" ||
+		query["rows"] != float64(3) || query["error"] != "timeout" {
+		t.Errorf("got %s", buf.String())
+	}
+}
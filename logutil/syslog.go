@@ -0,0 +1,490 @@
+package logutil
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"go.uber.org/zap/buffer"
+	"go.uber.org/zap/zapcore"
+)
+
+// syslog protocols of SyslogConfig.
+const (
+	SyslogRFC5424 = "rfc5424"
+	SyslogRFC3164 = "rfc3164"
+)
+
+// defaultSyslogSDID the SD-ID of the fields, 32473 is the example enterprise
+// number of RFC 5612.
+const defaultSyslogSDID = "fields@32473"
+
+// local syslog sockets, tried in order when SyslogConfig.Address is empty.
+var syslogSockets = []string{"/dev/log", "/var/run/syslog", "/var/run/log"}
+
+var syslogFacilities = map[string]int{
+	"kern":     0,
+	"user":     1,
+	"mail":     2,
+	"daemon":   3,
+	"auth":     4,
+	"syslog":   5,
+	"lpr":      6,
+	"news":     7,
+	"uucp":     8,
+	"cron":     9,
+	"authpriv": 10,
+	"ftp":      11,
+	"local0":   16,
+	"local1":   17,
+	"local2":   18,
+	"local3":   19,
+	"local4":   20,
+	"local5":   21,
+	"local6":   22,
+	"local7":   23,
+}
+
+// SyslogConfig syslog output config.
+type SyslogConfig struct {
+	Network  string `json:"network" yaml:"network"`   // 网络类型 unix unixgram udp tcp, 为空时连接本机 syslog
+	Address  string `json:"address" yaml:"address"`   // 地址, 为空时使用 /dev/log 等本机 socket
+	Protocol string `json:"protocol" yaml:"protocol"` // 协议 rfc5424 rfc3164, 默认 rfc5424
+	Facility string `json:"facility" yaml:"facility"` // 设施 kern user daemon local0-local7 等, 默认 user
+	Tag      string `json:"tag" yaml:"tag"`           // 应用名, 默认进程名
+	Hostname string `json:"hostname" yaml:"hostname"` // 主机名, 默认本机主机名
+	SDID     string `json:"sd_id" yaml:"sd_id"`       // rfc5424 结构化数据 ID, 默认 fields@32473
+}
+
+// syslogSeverity map zap levels to syslog severities.
+func syslogSeverity(level zapcore.Level) int {
+	switch level {
+	case zapcore.DebugLevel:
+		return 7 // debug
+	case zapcore.InfoLevel:
+		return 6 // info
+	case zapcore.WarnLevel:
+		return 4 // warning
+	case zapcore.ErrorLevel:
+		return 3 // err
+	case zapcore.DPanicLevel:
+		return 2 // crit
+	case zapcore.PanicLevel:
+		return 1 // alert
+	}
+	return 0 // emerg
+}
+
+// syslogCore write entries as syslog messages, fields are the SD-params of
+// rfc5424 messages and logfmt pairs after the message of rfc3164 messages.
+type syslogCore struct {
+	zapcore.LevelEnabler
+	cfg      SyslogConfig
+	facility int
+	pid      string
+	fields   []zapcore.Field
+	w        *syslogWriter
+	encoder  zapcore.Encoder // rfc3164 pairs, the message and time are in the header
+}
+
+// NewSyslogCore new core writing to syslog.
+func NewSyslogCore(cfg SyslogConfig, enab zapcore.LevelEnabler) (zapcore.Core, error) {
+	switch cfg.Protocol {
+	case "":
+		cfg.Protocol = SyslogRFC5424
+	case SyslogRFC5424, SyslogRFC3164:
+	default:
+		return nil, fmt.Errorf("unknown syslog protocol %q", cfg.Protocol)
+	}
+	if cfg.Facility == "" {
+		cfg.Facility = "user"
+	}
+	facility, ok := syslogFacilities[strings.ToLower(cfg.Facility)]
+	if !ok {
+		return nil, fmt.Errorf("unknown syslog facility %q", cfg.Facility)
+	}
+	switch cfg.Network {
+	case "", "unix", "unixgram", "udp", "udp4", "udp6", "tcp", "tcp4", "tcp6":
+	default:
+		return nil, fmt.Errorf("unknown syslog network %q", cfg.Network)
+	}
+	if cfg.Network != "" && cfg.Address == "" {
+		return nil, fmt.Errorf("syslog network %s without address", cfg.Network)
+	}
+	if cfg.Tag == "" {
+		cfg.Tag = filepath.Base(os.Args[0])
+	}
+	if cfg.Hostname == "" {
+		cfg.Hostname, _ = os.Hostname()
+	}
+	if cfg.SDID == "" {
+		cfg.SDID = defaultSyslogSDID
+	}
+
+	w := &syslogWriter{
+		network:       cfg.Network,
+		address:       cfg.Address,
+		octetCounting: cfg.Protocol == SyslogRFC5424,
+		timeout:       syslogTimeout,
+		now:           time.Now,
+	}
+	err := w.connect()
+	if err != nil {
+		return nil, err
+	}
+	return &syslogCore{
+		LevelEnabler: enab,
+		cfg:          cfg,
+		facility:     facility,
+		pid:          strconv.Itoa(os.Getpid()),
+		w:            w,
+		encoder: NewLogfmtEncoder(zapcore.EncoderConfig{
+			NameKey:        "logger",
+			CallerKey:      "caller",
+			StacktraceKey:  "stacktrace",
+			EncodeCaller:   zapcore.ShortCallerEncoder,
+			SkipLineEnding: true,
+		}),
+	}, nil
+}
+
+func (c *syslogCore) With(fields []zapcore.Field) zapcore.Core {
+	clone := *c
+	clone.fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	return &clone
+}
+
+func (c *syslogCore) Check(ent zapcore.Entry, ce *zapcore.CheckedEntry) *zapcore.CheckedEntry {
+	if c.Enabled(ent.Level) {
+		return ce.AddCore(ent, c)
+	}
+	return ce
+}
+
+func (c *syslogCore) Write(ent zapcore.Entry, fields []zapcore.Field) error {
+	fields = append(c.fields[:len(c.fields):len(c.fields)], fields...)
+	var msg []byte
+	if c.cfg.Protocol == SyslogRFC3164 {
+		buf, err := c.encoder.EncodeEntry(ent, fields)
+		if err != nil {
+			return err
+		}
+		msg = c.rfc3164(ent, buf.String())
+		buf.Free()
+	} else {
+		msg = c.rfc5424(ent, flattenFields(fields))
+	}
+	return c.w.write(msg)
+}
+
+func (c *syslogCore) Sync() error {
+	return nil
+}
+
+// Close close the connection to syslog.
+func (c *syslogCore) Close() error {
+	return c.w.close()
+}
+
+// rfc5424 <PRI>1 TIMESTAMP HOSTNAME APP-NAME PROCID MSGID [SD-ID name="value"] MSG,
+// the logger name is the MSGID.
+func (c *syslogCore) rfc5424(ent zapcore.Entry, fields []fieldValue) []byte {
+	buf := bufferPool.Get()
+	defer buf.Free()
+
+	buf.AppendString(fmt.Sprintf("<%d>1 ", c.facility*8+syslogSeverity(ent.Level)))
+	buf.AppendString(ent.Time.Format("2006-01-02T15:04:05.000000Z07:00"))
+	buf.AppendByte(' ')
+	buf.AppendString(syslogHeaderValue(c.cfg.Hostname, 255))
+	buf.AppendByte(' ')
+	buf.AppendString(syslogHeaderValue(c.cfg.Tag, 48))
+	buf.AppendByte(' ')
+	buf.AppendString(c.pid)
+	buf.AppendByte(' ')
+	buf.AppendString(syslogHeaderValue(ent.LoggerName, 32))
+	buf.AppendByte(' ')
+
+	if ent.Caller.Defined {
+		fields = append([]fieldValue{{key: "caller", value: ent.Caller.TrimmedPath()}}, fields...)
+	}
+	if len(fields) == 0 {
+		buf.AppendByte('-')
+	} else {
+		buf.AppendByte('[')
+		buf.AppendString(c.cfg.SDID)
+		for _, f := range fields {
+			buf.AppendByte(' ')
+			buf.AppendString(sdName(f.key))
+			buf.AppendString(`="`)
+			buf.AppendString(sdEscaper.Replace(f.value))
+			buf.AppendByte('"')
+		}
+		buf.AppendByte(']')
+	}
+
+	buf.AppendByte(' ')
+	buf.AppendString(ent.Message)
+	if ent.Stack != "" {
+		buf.AppendByte('\n')
+		buf.AppendString(ent.Stack)
+	}
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+// rfc3164 <PRI>Mmm dd hh:mm:ss HOSTNAME TAG[PID]: MSG key=value, the
+// message is on one line.
+func (c *syslogCore) rfc3164(ent zapcore.Entry, pairs string) []byte {
+	buf := bufferPool.Get()
+	defer buf.Free()
+
+	buf.AppendString(fmt.Sprintf("<%d>", c.facility*8+syslogSeverity(ent.Level)))
+	buf.AppendString(ent.Time.Format(time.Stamp))
+	buf.AppendByte(' ')
+	buf.AppendString(syslogHeaderValue(c.cfg.Hostname, 255))
+	buf.AppendByte(' ')
+	buf.AppendString(syslogHeaderValue(c.cfg.Tag, 32))
+	buf.AppendString("[" + c.pid + "]: ")
+	buf.AppendString(singleLine(ent.Message))
+	if pairs != "" {
+		buf.AppendByte(' ')
+		buf.AppendString(pairs)
+	}
+	return append([]byte(nil), buf.Bytes()...)
+}
+
+// syslogHeaderValue returns "-" for empty values, spaces and other not
+// printable characters are replaced by '_'.
+func syslogHeaderValue(value string, maxLen int) string {
+	if value == "" {
+		return "-"
+	}
+	return sanitizeASCII(value, maxLen, "")
+}
+
+// sdName returns the SD-NAME of the key, '=', ' ', ']' and '"' are not allowed.
+func sdName(key string) string {
+	return sanitizeASCII(key, 32, `=]"`)
+}
+
+func sanitizeASCII(value string, maxLen int, invalid string) string {
+	b := []byte(value)
+	if len(b) > maxLen {
+		b = b[:maxLen]
+	}
+	for i, c := range b {
+		if c <= ' ' || c > '~' || strings.IndexByte(invalid, c) >= 0 {
+			b[i] = '_'
+		}
+	}
+	return string(b)
+}
+
+var sdEscaper = strings.NewReplacer(`"`, `\"`, `\`, `\\`, `]`, `\]`)
+
+func singleLine(s string) string {
+	if !strings.ContainsAny(s, "\r\n") {
+		return s
+	}
+	return strings.NewReplacer("\r", `\r`, "\n", `\n`).Replace(s)
+}
+
+var bufferPool = buffer.NewPool()
+
+// fieldValue a flattened field.
+type fieldValue struct {
+	key   string
+	value string
+}
+
+// flattenFields encode fields to key value strings, nested objects use
+// dotted keys, arrays are json. Keys are sorted.
+func flattenFields(fields []zapcore.Field) []fieldValue {
+	enc := zapcore.NewMapObjectEncoder()
+	for _, f := range fields {
+		f.AddTo(enc)
+	}
+	var values []fieldValue
+	flattenMap("", enc.Fields, &values)
+	sort.Slice(values, func(i, j int) bool { return values[i].key < values[j].key })
+	return values
+}
+
+func flattenMap(prefix string, m map[string]interface{}, values *[]fieldValue) {
+	for key, value := range m {
+		if prefix != "" {
+			key = prefix + "." + key
+		}
+		if nested, ok := value.(map[string]interface{}); ok {
+			flattenMap(key, nested, values)
+			continue
+		}
+		*values = append(*values, fieldValue{key: key, value: valueString(value)})
+	}
+}
+
+func valueString(value interface{}) string {
+	switch v := value.(type) {
+	case nil:
+		return ""
+	case string:
+		return v
+	case []byte:
+		return string(v)
+	case time.Time:
+		return v.Format(time.RFC3339Nano)
+	case time.Duration:
+		return v.String()
+	case error:
+		return v.Error()
+	case fmt.Stringer:
+		return v.String()
+	case bool, int, int8, int16, int32, int64, uint, uint8, uint16, uint32, uint64, uintptr, float32, float64, complex64, complex128:
+		return fmt.Sprint(v)
+	}
+	data, err := json.Marshal(value)
+	if err != nil {
+		return fmt.Sprint(value)
+	}
+	return string(data)
+}
+
+// syslog connection settings, reconnects back off from syslogRetryMin to
+// syslogRetryMax while the server is unreachable.
+const (
+	syslogTimeout  = 5 * time.Second
+	syslogRetryMin = 100 * time.Millisecond
+	syslogRetryMax = time.Minute
+)
+
+var errSyslogClosed = errors.New("syslog writer is closed")
+
+// syslogWriter send messages to syslog, it reconnects once when a write fails.
+// Dials and writes are bounded by timeout so a stalled server does not block
+// the logging calls, reconnects after timeouts and failed dials back off.
+type syslogWriter struct {
+	network       string
+	address       string
+	octetCounting bool // rfc5424 stream framing, rfc3164 messages end with a newline
+	timeout       time.Duration
+	now           func() time.Time
+
+	mu      sync.Mutex
+	conn    net.Conn
+	stream  bool
+	closed  bool
+	retry   time.Duration // backoff of the next failed connect
+	retryAt time.Time     // no connect is tried before it
+}
+
+func (w *syslogWriter) connect() error {
+	if w.network != "" {
+		conn, err := net.DialTimeout(w.network, w.address, w.timeout)
+		if err != nil {
+			return err
+		}
+		w.conn = conn
+		w.stream = w.network == "unix" || strings.HasPrefix(w.network, "tcp")
+		return nil
+	}
+
+	addresses := syslogSockets
+	if w.address != "" {
+		addresses = []string{w.address}
+	}
+	for _, address := range addresses {
+		for _, network := range []string{"unixgram", "unix"} {
+			conn, err := net.DialTimeout(network, address, w.timeout)
+			if err == nil {
+				w.conn = conn
+				w.stream = network == "unix"
+				return nil
+			}
+		}
+	}
+	return errors.New("no local syslog socket found")
+}
+
+func (w *syslogWriter) write(msg []byte) error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	if w.closed {
+		return errSyslogClosed
+	}
+	if w.conn != nil {
+		err := w.send(msg)
+		if err == nil {
+			w.retry = 0
+			return nil
+		}
+		_ = w.conn.Close()
+		w.conn = nil
+		// a stalled server is not redialed at once.
+		if netErr, ok := err.(net.Error); ok && netErr.Timeout() {
+			w.backoff()
+			return err
+		}
+	}
+
+	if w.now().Before(w.retryAt) {
+		return fmt.Errorf("syslog reconnect backing off until %s", w.retryAt.Format(time.RFC3339Nano))
+	}
+	err := w.connect()
+	if err != nil {
+		w.backoff()
+		return err
+	}
+	err = w.send(msg)
+	if err != nil {
+		return err
+	}
+	w.retry = 0
+	return nil
+}
+
+// backoff delay the next connect, the delay doubles until a send succeeds.
+func (w *syslogWriter) backoff() {
+	w.retry *= 2
+	if w.retry == 0 {
+		w.retry = syslogRetryMin
+	}
+	if w.retry > syslogRetryMax {
+		w.retry = syslogRetryMax
+	}
+	w.retryAt = w.now().Add(w.retry)
+}
+
+func (w *syslogWriter) send(msg []byte) error {
+	if w.stream {
+		if w.octetCounting {
+			msg = append([]byte(strconv.Itoa(len(msg))+" "), msg...)
+		} else {
+			msg = append(msg, '\n')
+		}
+	}
+	err := w.conn.SetWriteDeadline(time.Now().Add(w.timeout))
+	if err != nil {
+		return err
+	}
+	_, err = w.conn.Write(msg)
+	return err
+}
+
+func (w *syslogWriter) close() error {
+	w.mu.Lock()
+	defer w.mu.Unlock()
+	w.closed = true
+	if w.conn == nil {
+		return nil
+	}
+	err := w.conn.Close()
+	w.conn = nil
+	return err
+}
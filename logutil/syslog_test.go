@@ -0,0 +1,256 @@
+package logutil
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/binary"
+	"fmt"
+	"net"
+	"os"
+	"path/filepath"
+	"strings"
+	"testing"
+	"time"
+
+	"go.uber.org/zap"
+	"go.uber.org/zap/zapcore"
+)
+
+func TestSyslogRFC5424UDP(t *testing.T) {
+	conn, err := net.ListenPacket("udp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	l, err := New(&Config{
+		LogLevel: "debug",
+		Outputs: []Output{{
+			Type: OutputSyslog,
+			Syslog: &SyslogConfig{
+				Network:  "udp",
+				Address:  conn.LocalAddr().String(),
+				Facility: "local0",
+				Tag:      "app",
+				Hostname: "host",
+			},
+		}},
+	})
+	if err != nil {
+		t.Fatal(err)
+	}
+	l.Named("db").Warn("slow query", zap.String("sql", `select "x"]`), zap.Object("user", logfmtUser{Name: "foo"}))
+
+	msg := readPacket(t, conn)
+	// local0 * 8 + warning
+	if !strings.HasPrefix(msg, "<132>1 ") {
+		t.Fatalf("unexpected priority %q", msg)
+	}
+	want := ` host app ` + strings.Split(msg, " ")[4] + ` db [fields@32473 sql="select \"x\"\]" user.name="foo" user.roles="[\]"] slow query`
+	if !strings.HasSuffix(msg, want) {
+		t.Fatalf("got %q, want suffix %q", msg, want)
+	}
+}
+
+func TestSyslogRFC3164TCP(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	lines := make(chan string, 2)
+	go func() {
+		conn, err := ln.Accept()
+		if err != nil {
+			return
+		}
+		defer conn.Close()
+		scanner := bufio.NewScanner(conn)
+		for scanner.Scan() {
+			lines <- scanner.Text()
+		}
+	}()
+
+	core, err := NewSyslogCore(SyslogConfig{
+		Network:  "tcp",
+		Address:  ln.Addr().String(),
+		Protocol: SyslogRFC3164,
+		Tag:      "app",
+		Hostname: "host",
+	}, zapcore.DebugLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(core).With(zap.Int("id", 1))
+	l.Error("first\nline", zap.String("name", "a b"))
+	l.Debug("second")
+
+	for _, want := range []string{
+		`<11>Stamp host app[PID]: first\nline id=1 name="a b"`,
+		`<15>Stamp host app[PID]: second id=1`,
+	} {
+		select {
+		case line := <-lines:
+			// replace the Mmm dd hh:mm:ss timestamp and the pid
+			i := strings.IndexByte(line, '>') + 1
+			line = line[:i] + "Stamp" + line[i+len(time.Stamp):]
+			line = strings.Replace(line, fmt.Sprintf("[%d]", os.Getpid()), "[PID]", 1)
+			if line != want {
+				t.Fatalf("got %q, want %q", line, want)
+			}
+		case <-time.After(time.Second):
+			t.Fatal("timeout")
+		}
+	}
+}
+
+func TestJournald(t *testing.T) {
+	socket := filepath.Join(t.TempDir(), "journal.sock")
+	conn, err := net.ListenUnixgram("unixgram", &net.UnixAddr{Name: socket, Net: "unixgram"})
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer conn.Close()
+
+	core, err := NewJournaldCore(JournaldConfig{Socket: socket, Identifier: "app"}, zapcore.InfoLevel)
+	if err != nil {
+		t.Fatal(err)
+	}
+	l := zap.New(core).Named("db")
+	l.Info("multi\nline", zap.String("user.id", "42"), zap.Int("_private", 1))
+
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, err := conn.Read(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	fields := parseJournal(t, buf[:n])
+	want := map[string]string{
+		"MESSAGE":           "multi\nline",
+		"PRIORITY":          "6",
+		"SYSLOG_IDENTIFIER": "app",
+		"LOGGER":            "db",
+		"USER_ID":           "42",
+		"PRIVATE":           "1",
+	}
+	for key, value := range want {
+		if fields[key] != value {
+			t.Fatalf("%s: got %q, want %q", key, fields[key], value)
+		}
+	}
+}
+
+func readPacket(t *testing.T, conn net.PacketConn) string {
+	t.Helper()
+	buf := make([]byte, 4096)
+	_ = conn.SetReadDeadline(time.Now().Add(time.Second))
+	n, _, err := conn.ReadFrom(buf)
+	if err != nil {
+		t.Fatal(err)
+	}
+	return string(buf[:n])
+}
+
+func parseJournal(t *testing.T, data []byte) map[string]string {
+	t.Helper()
+	fields := make(map[string]string)
+	for len(data) > 0 {
+		i := bytes.IndexAny(data, "=\n")
+		if i < 0 {
+			t.Fatalf("invalid journal data %q", data)
+		}
+		key := string(data[:i])
+		if data[i] == '=' {
+			end := bytes.IndexByte(data, '\n')
+			fields[key] = string(data[i+1 : end])
+			data = data[end+1:]
+			continue
+		}
+		size := binary.LittleEndian.Uint64(data[i+1:])
+		start := i + 9
+		fields[key] = string(data[start : start+int(size)])
+		data = data[start+int(size)+1:]
+	}
+	return fields
+}
+
+func TestSyslogWriterTimeout(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer ln.Close()
+	accepted := make(chan net.Conn, 2)
+	go func() {
+		for {
+			conn, err := ln.Accept()
+			if err != nil {
+				return
+			}
+			// never read, the server stalls.
+			accepted <- conn
+		}
+	}()
+
+	w := &syslogWriter{network: "tcp", address: ln.Addr().String(), timeout: 50 * time.Millisecond, now: time.Now}
+	msg := bytes.Repeat([]byte("x"), 64<<10)
+	start := time.Now()
+	for err == nil && time.Since(start) < 5*time.Second {
+		err = w.write(msg)
+	}
+	if netErr, ok := err.(net.Error); !ok || !netErr.Timeout() {
+		t.Fatalf("expected timeout error, got %v after %s", err, time.Since(start))
+	}
+	// the stalled server is not redialed at once.
+	err = w.write(msg)
+	if err == nil || !strings.Contains(err.Error(), "backing off") {
+		t.Fatalf("expected backoff error, got %v", err)
+	}
+
+	// no redial after close.
+	<-accepted
+	err = w.close()
+	if err != nil {
+		t.Fatal(err)
+	}
+	if err := w.write([]byte("after close")); err != errSyslogClosed {
+		t.Fatalf("unexpected error %v", err)
+	}
+	select {
+	case conn := <-accepted:
+		conn.Close()
+		t.Fatal("redialed after close")
+	case <-time.After(100 * time.Millisecond):
+	}
+}
+
+func TestSyslogWriterBackoff(t *testing.T) {
+	ln, err := net.Listen("tcp", "127.0.0.1:0")
+	if err != nil {
+		t.Fatal(err)
+	}
+	address := ln.Addr().String()
+	ln.Close()
+
+	now := time.Now()
+	w := &syslogWriter{network: "tcp", address: address, timeout: time.Second, now: func() time.Time { return now }}
+	err = w.write([]byte("a"))
+	if err == nil || strings.Contains(err.Error(), "backing off") {
+		t.Fatalf("expected dial error, got %v", err)
+	}
+	err = w.write([]byte("b"))
+	if err == nil || !strings.Contains(err.Error(), "backing off") {
+		t.Fatalf("expected backoff error, got %v", err)
+	}
+
+	// the backoff doubles after every failed connect.
+	now = now.Add(syslogRetryMin)
+	err = w.write([]byte("c"))
+	if err == nil || strings.Contains(err.Error(), "backing off") {
+		t.Fatalf("expected dial error, got %v", err)
+	}
+	if w.retry != 2*syslogRetryMin {
+		t.Fatalf("retry %s, want %s", w.retry, 2*syslogRetryMin)
+	}
+}